@@ -8,12 +8,17 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/cdzombak/mac-install/internal/toolchain"
 	"gopkg.in/yaml.v3"
 )
 
 //go:embed internal.yaml
 var internalConfigData []byte
 
+// pathStepKeys are install/configure step parameters holding filesystem paths,
+// which support the same variable expansion as artifact paths
+var pathStepKeys = []string{"dest", "link", "copy", "template", "log", "login_item", "dock", "folders"}
+
 type Config struct {
 	Checklist     string         `yaml:"checklist"`
 	InstallGroups []InstallGroup `yaml:"install_groups"`
@@ -33,6 +38,11 @@ type Software struct {
 	Configure []map[string]string `yaml:"configure,omitempty"`
 	Checklist []string            `yaml:"checklist,omitempty"`
 	Persist   *bool               `yaml:"persist,omitempty"`
+	DependsOn []string            `yaml:"depends_on,omitempty"`
+
+	// InferredArtifact is set when the artifact defaults to a command named
+	// after the package a toolchain method installs, which may be wrong
+	InferredArtifact bool `yaml:"-"`
 }
 
 func Load(filename string) (*Config, error) {
@@ -41,17 +51,151 @@ func Load(filename string) (*Config, error) {
 		return nil, err
 	}
 
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	config, err := parse(data)
+	if err != nil {
 		return nil, err
 	}
 
 	if err := config.expandVariables(); err != nil {
 		return nil, err
 	}
+
+	// Validated after expansion, since unnamed software is named by its artifact
+	if err := config.validateDependencies(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// validateDependencies checks that each depends_on entry names software defined
+// earlier in the configuration, since software is processed in order
+func (c *Config) validateDependencies() error {
+	defined := make(map[string]bool)
+	for _, group := range c.InstallGroups {
+		for _, software := range group.Software {
+			for _, dependency := range software.DependsOn {
+				if !defined[dependency] {
+					return fmt.Errorf("%s depends on '%s', which is not defined before it", software.GetDisplayName(), dependency)
+				}
+			}
+			defined[software.GetDisplayName()] = true
+		}
+	}
+	return nil
+}
+
+// parse decodes configuration YAML. Install and configure step values that are
+// YAML sequences or mappings are kept as their YAML encoding, so methods that take
+// structured parameters can decode them while steps remain map[string]string.
+func parse(data []byte) (*Config, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	if err := flattenStepValues(&root); err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := root.Decode(&config); err != nil {
+		return nil, err
+	}
 	return &config, nil
 }
 
+// StepListMarker begins step values flattened from YAML sequences. It is a YAML
+// comment, so the value still decodes as the sequence, but it tells list
+// parameters apart from scalars that merely look like YAML.
+const StepListMarker = "# sequence\n"
+
+func flattenStepValues(root *yaml.Node) error {
+	if root.Kind == yaml.DocumentNode {
+		if len(root.Content) == 0 {
+			return nil
+		}
+		root = root.Content[0]
+	}
+
+	for _, group := range sequenceItems(mappingValue(root, "install_groups")) {
+		for _, software := range sequenceItems(mappingValue(group, "software")) {
+			for _, key := range []string{"install", "configure"} {
+				for _, step := range sequenceItems(mappingValue(software, key)) {
+					if step.Kind != yaml.MappingNode {
+						continue
+					}
+					for idx := 1; idx < len(step.Content); idx += 2 {
+						value := step.Content[idx]
+						if value.Kind != yaml.SequenceNode && value.Kind != yaml.MappingNode {
+							continue
+						}
+						encoded, err := yaml.Marshal(value)
+						if err != nil {
+							return fmt.Errorf("failed to encode %s step value: %w", key, err)
+						}
+						if value.Kind == yaml.SequenceNode {
+							encoded = append([]byte(StepListMarker), encoded...)
+						}
+						*value = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: string(encoded)}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for idx := 0; idx+1 < len(node.Content); idx += 2 {
+		if node.Content[idx].Value == key {
+			return node.Content[idx+1]
+		}
+	}
+	return nil
+}
+
+func sequenceItems(node *yaml.Node) []*yaml.Node {
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return node.Content
+}
+
+// expandStepPaths expands variables in a path step parameter, or in each path of
+// a list, so every item of a list can start with ~
+func (c *Config) expandStepPaths(value, homeDir, brewPrefix string) (string, error) {
+	expand := func(path string) (string, error) {
+		path = strings.ReplaceAll(path, "$HOME", homeDir)
+		path = strings.ReplaceAll(path, "$BREW", brewPrefix)
+		path = expandTildePath(path, homeDir)
+		return c.expandEnvVariables(path)
+	}
+
+	if !strings.HasPrefix(value, StepListMarker) {
+		return expand(value)
+	}
+	var items []string
+	if err := yaml.Unmarshal([]byte(value), &items); err != nil {
+		// Nested lists aren't paths; leave them for the step to reject
+		return value, nil
+	}
+	for idx, item := range items {
+		expanded, err := expand(item)
+		if err != nil {
+			return "", err
+		}
+		items[idx] = expanded
+	}
+	encoded, err := yaml.Marshal(items)
+	if err != nil {
+		return "", err
+	}
+	return StepListMarker + string(encoded), nil
+}
+
 // expandTildePath expands ~ to the user's home directory
 func expandTildePath(path, homeDir string) string {
 	if len(path) == 0 || path[0] != '~' {
@@ -65,29 +209,96 @@ func expandTildePath(path, homeDir string) string {
 	return homeDir + path[1:]
 }
 
+// ExtensionArtifactPrefix begins artifacts naming an editor extension rather than
+// a path: "vscode_extension:<id>", or "vscode_extension:<cli>:<id>"
+const ExtensionArtifactPrefix = "vscode_extension:"
+
+// defaultArtifact returns the artifact of software installed by a language
+// toolchain method (cargo, go_install, uv_tool or pip_user), a runtime or an
+// editor extension, so those need not name one, and whether the command name in
+// it was inferred from a package name. It returns "" if no install step uses
+// such a method, and an error if a runtime step doesn't name its manager or uses
+// an unset $ENV_ variable.
+func defaultArtifact(installSteps []map[string]string) (string, bool, error) {
+	for _, step := range installSteps {
+		if artifact, ok := toolchain.CommandArtifact(step); ok {
+			return artifact, true, nil
+		}
+		if extension, ok := step["vscode_extension"]; ok {
+			id, _, _ := strings.Cut(strings.TrimSpace(extension), "@")
+			if editor := strings.TrimSpace(step["editor"]); editor != "" {
+				return ExtensionArtifactPrefix + editor + ":" + id, false, nil
+			}
+			return ExtensionArtifactPrefix + id, false, nil
+		}
+		if runtime, ok := step["runtime"]; ok {
+			// The version may come from the environment, and decides the artifact
+			runtime, err := ExpandEnvVariables(runtime)
+			if err != nil {
+				return "", false, err
+			}
+			// Which manager is available can change during the run, so the
+			// artifact can only be derived from one named explicitly
+			manager := strings.ToLower(strings.TrimSpace(step["manager"]))
+			if manager == "" {
+				return "", false, fmt.Errorf("'runtime' requires 'manager' (mise or asdf) when the software has no artifact")
+			}
+			return toolchain.RuntimeArtifact(manager, runtime), false, nil
+		}
+	}
+	return "", false, nil
+}
+
+// BrewPrefix returns the Homebrew prefix, which $BREW expands to
+func BrewPrefix() string {
+	if _, err := os.Stat("/usr/local/bin/brew"); err == nil {
+		return "/usr/local"
+	}
+	return "/opt/homebrew"
+}
+
 func (c *Config) expandVariables() error {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return err
 	}
-	brewPrefix := "/opt/homebrew"
-	if _, err := os.Stat("/usr/local/bin/brew"); err == nil {
-		brewPrefix = "/usr/local"
-	}
+	brewPrefix := BrewPrefix()
 
 	for i := range c.InstallGroups {
 		for j := range c.InstallGroups[i].Software {
 			software := &c.InstallGroups[i].Software[j]
+			if strings.TrimSpace(software.Artifact) == "" {
+				software.Artifact, software.InferredArtifact, err = defaultArtifact(software.Install)
+				if err != nil {
+					return fmt.Errorf("failed to determine the artifact for %s: %w", software.Name, err)
+				}
+			}
 			software.Artifact = strings.ReplaceAll(software.Artifact, "$HOME", homeDir)
 			software.Artifact = strings.ReplaceAll(software.Artifact, "$BREW", brewPrefix)
 			software.Artifact = expandTildePath(software.Artifact, homeDir)
 
 			// Handle $ENV_ variables
-			var err error
 			software.Artifact, err = c.expandEnvVariables(software.Artifact)
 			if err != nil {
 				return fmt.Errorf("failed to expand environment variables in artifact path for %s: %w", software.Name, err)
 			}
+
+			// Path-valued step parameters get the same expansion as artifacts
+			for _, steps := range [][]map[string]string{software.Install, software.Configure} {
+				for _, step := range steps {
+					for _, key := range pathStepKeys {
+						value, ok := step[key]
+						if !ok {
+							continue
+						}
+						value, err = c.expandStepPaths(value, homeDir, brewPrefix)
+						if err != nil {
+							return fmt.Errorf("failed to expand environment variables in %s for %s: %w", key, software.Name, err)
+						}
+						step[key] = value
+					}
+				}
+			}
 		}
 	}
 
@@ -106,6 +317,13 @@ func (c *Config) expandVariables() error {
 
 // expandEnvVariables expands environment variables using $ENV_ prefix
 func (c *Config) expandEnvVariables(input string) (string, error) {
+	return ExpandEnvVariables(input)
+}
+
+// ExpandEnvVariables expands $ENV_NAME to the value of the environment variable
+// NAME, failing if it is unset. It is used for step values that aren't paths,
+// such as runtime versions.
+func ExpandEnvVariables(input string) (string, error) {
 	// Regular expression to match $ENV_VARIABLE_NAME patterns
 	envVarRegex := regexp.MustCompile(`\$ENV_([A-Z_][A-Z0-9_]*)`)
 
@@ -133,6 +351,11 @@ func (c *Config) expandEnvVariables(input string) (string, error) {
 func (s *Software) GetArtifactDisplayName() string {
 	artifact := s.Artifact
 
+	// Editor extensions are named by their ID, after the optional editor CLI
+	if extension, ok := strings.CutPrefix(artifact, ExtensionArtifactPrefix); ok {
+		return extension[strings.LastIndex(extension, ":")+1:]
+	}
+
 	if homeDir, err := os.UserHomeDir(); err == nil {
 		if strings.HasPrefix(artifact, homeDir) {
 			artifact = "~" + strings.TrimPrefix(artifact, homeDir)
@@ -171,15 +394,15 @@ func (g *InstallGroup) IsOptional() bool {
 }
 
 func LoadInternal() (*Config, error) {
-	var config Config
-	if err := yaml.Unmarshal(internalConfigData, &config); err != nil {
+	config, err := parse(internalConfigData)
+	if err != nil {
 		return nil, err
 	}
 
 	if err := config.expandVariables(); err != nil {
 		return nil, err
 	}
-	return &config, nil
+	return config, nil
 }
 
 func (c *Config) RequiresHomebrew() bool {
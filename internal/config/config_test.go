@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -100,6 +101,220 @@ func TestExpandVariables(t *testing.T) {
 	}
 }
 
+func TestLoadStructuredStepValues(t *testing.T) {
+	homeDir, _ := os.UserHomeDir()
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, "test-config.yaml")
+
+	configContent := `checklist: /Users/test/SystemSetup.md
+
+install_groups:
+  - group: Test Group
+    software:
+      - name: Test Tool
+        artifact: $HOME/bin/tool
+        install:
+          - archive: https://example.com/tool.tar.gz
+            file:
+              - tool
+              - tool-helper
+            dest: ~/bin
+        configure:
+          - link: dotfiles/toolrc
+            dest: $HOME/.toolrc
+          - dock: Safari
+            folders:
+              - ~/Downloads
+              - $HOME/Documents
+              - /Applications
+`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := Load(configFile)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	step := config.InstallGroups[0].Software[0].Install[0]
+
+	if !strings.HasPrefix(step["file"], StepListMarker) {
+		t.Errorf("Step value flattened from a sequence should start with the list marker, got %q", step["file"])
+	}
+	var files []string
+	if err := yaml.Unmarshal([]byte(step["file"]), &files); err != nil {
+		t.Fatalf("Structured step value should be valid YAML: %v", err)
+	}
+	if len(files) != 2 || files[0] != "tool" || files[1] != "tool-helper" {
+		t.Errorf("Expected [tool tool-helper], got %v", files)
+	}
+
+	if step["dest"] != filepath.Join(homeDir, "bin") {
+		t.Errorf("Expected dest to be expanded to '%s', got '%s'", filepath.Join(homeDir, "bin"), step["dest"])
+	}
+
+	configureStep := config.InstallGroups[0].Software[0].Configure[0]
+	if configureStep["link"] != "dotfiles/toolrc" {
+		t.Errorf("Relative link source should be left as is, got '%s'", configureStep["link"])
+	}
+	if configureStep["dest"] != filepath.Join(homeDir, ".toolrc") {
+		t.Errorf("Expected configure dest to be expanded to '%s', got '%s'", filepath.Join(homeDir, ".toolrc"), configureStep["dest"])
+	}
+
+	// Each path in a list is expanded, not just the start of the list
+	dockStep := config.InstallGroups[0].Software[0].Configure[1]
+	if !strings.HasPrefix(dockStep["folders"], StepListMarker) {
+		t.Errorf("Expanded list should keep the list marker, got %q", dockStep["folders"])
+	}
+	var folders []string
+	if err := yaml.Unmarshal([]byte(dockStep["folders"]), &folders); err != nil {
+		t.Fatal(err)
+	}
+	expectedFolders := []string{filepath.Join(homeDir, "Downloads"), filepath.Join(homeDir, "Documents"), "/Applications"}
+	if !reflect.DeepEqual(folders, expectedFolders) {
+		t.Errorf("Expected folders %v, got %v", expectedFolders, folders)
+	}
+}
+
+func TestDefaultArtifact(t *testing.T) {
+	for _, name := range []string{"UV_TOOL_BIN_DIR", "XDG_BIN_HOME"} {
+		t.Setenv(name, "")
+	}
+
+	// Software without an artifact gets the default, expanded
+	homeDir, _ := os.UserHomeDir()
+	config := &Config{InstallGroups: []InstallGroup{{
+		Group: "Tools",
+		Software: []Software{
+			{Install: []map[string]string{{"uv_tool": "ruff"}}},
+			{Artifact: "$HOME/.cargo/bin/rg", Install: []map[string]string{{"cargo": "ripgrep"}}},
+			{Artifact: "$BREW/bin/git", Install: []map[string]string{{"brew": "git"}}},
+		},
+	}}}
+	if err := config.expandVariables(); err != nil {
+		t.Fatal(err)
+	}
+	software := config.InstallGroups[0].Software[0]
+	if software.Artifact != homeDir+"/.local/bin/ruff" {
+		t.Errorf("Expected default artifact to be expanded, got '%s'", software.Artifact)
+	}
+	if !software.InferredArtifact {
+		t.Error("A default command artifact should be marked as inferred")
+	}
+	if software.GetDisplayName() != "ruff" {
+		t.Errorf("Expected display name 'ruff', got '%s'", software.GetDisplayName())
+	}
+	for _, software := range config.InstallGroups[0].Software[1:] {
+		if software.InferredArtifact {
+			t.Errorf("A given artifact should not be marked as inferred: %s", software.Artifact)
+		}
+	}
+
+	// Extensions are named exactly, in the editor that installs them
+	for expected, step := range map[string]map[string]string{
+		"vscode_extension:golang.go":        {"vscode_extension": "golang.go@0.41.0"},
+		"vscode_extension:cursor:golang.go": {"vscode_extension": "golang.go", "editor": "cursor"},
+	} {
+		if artifact, inferred, err := defaultArtifact([]map[string]string{step}); artifact != expected || inferred || err != nil {
+			t.Errorf("Expected default artifact '%s', got '%s' (inferred %v, %v)", expected, artifact, inferred, err)
+		}
+	}
+
+	if artifact, inferred, err := defaultArtifact([]map[string]string{{"brew": "git"}}); artifact != "" || inferred || err != nil {
+		t.Errorf("Expected no default artifact for brew, got '%s'", artifact)
+	}
+}
+
+func TestDefaultRuntimeArtifact(t *testing.T) {
+	t.Setenv("ASDF_DATA_DIR", "/data/asdf")
+
+	config := &Config{InstallGroups: []InstallGroup{{
+		Group:    "Runtimes",
+		Software: []Software{{Name: "Python", Install: []map[string]string{{"runtime": "python@3.12", "manager": "asdf"}}}},
+	}}}
+	if err := config.expandVariables(); err != nil {
+		t.Fatal(err)
+	}
+	if artifact := config.InstallGroups[0].Software[0].Artifact; artifact != "/data/asdf/installs/python/3.12.*" {
+		t.Errorf("Expected the runtime's install directory, got '%s'", artifact)
+	}
+
+	// A version from the environment is expanded before the artifact is derived
+	t.Setenv("ASDF_PY", "3.12")
+	config = &Config{InstallGroups: []InstallGroup{{
+		Group:    "Runtimes",
+		Software: []Software{{Name: "Python", Install: []map[string]string{{"runtime": "python@$ENV_ASDF_PY", "manager": "asdf"}}}},
+	}}}
+	if err := config.expandVariables(); err != nil {
+		t.Fatal(err)
+	}
+	if artifact := config.InstallGroups[0].Software[0].Artifact; artifact != "/data/asdf/installs/python/3.12.*" {
+		t.Errorf("Expected the environment's partial version to be matched, got '%s'", artifact)
+	}
+
+	// Without an artifact, the manager can't be left to whichever is installed
+	config = &Config{InstallGroups: []InstallGroup{{
+		Group:    "Runtimes",
+		Software: []Software{{Name: "Python", Install: []map[string]string{{"runtime": "python@3.12"}}}},
+	}}}
+	if err := config.expandVariables(); err == nil || !strings.Contains(err.Error(), "requires 'manager'") {
+		t.Errorf("Expected an error requiring 'manager', got %v", err)
+	}
+}
+
+func TestValidateDependencies(t *testing.T) {
+	tests := []struct {
+		name        string
+		software    []Software
+		shouldError bool
+	}{
+		{
+			name: "depends on earlier software",
+			software: []Software{
+				{Name: "Node", Artifact: "/opt/homebrew/bin/node"},
+				{Name: "Prettier", Artifact: "/opt/homebrew/bin/prettier", DependsOn: []string{"Node"}},
+			},
+		},
+		{
+			name: "depends on unnamed software by display name",
+			software: []Software{
+				{Artifact: "/Applications/Docker.app"},
+				{Name: "Docker Setup", Artifact: "/tmp/docker-setup", DependsOn: []string{"Docker"}},
+			},
+		},
+		{
+			name: "depends on later software",
+			software: []Software{
+				{Name: "Prettier", Artifact: "/opt/homebrew/bin/prettier", DependsOn: []string{"Node"}},
+				{Name: "Node", Artifact: "/opt/homebrew/bin/node"},
+			},
+			shouldError: true,
+		},
+		{
+			name: "depends on unknown software",
+			software: []Software{
+				{Name: "Prettier", Artifact: "/opt/homebrew/bin/prettier", DependsOn: []string{"Nodejs"}},
+			},
+			shouldError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := &Config{InstallGroups: []InstallGroup{{Group: "Test", Software: test.software}}}
+			err := config.validateDependencies()
+			if test.shouldError && err == nil {
+				t.Error("Expected error")
+			}
+			if !test.shouldError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
 func TestGetArtifactDisplayName(t *testing.T) {
 	homeDir, _ := os.UserHomeDir()
 
@@ -115,6 +330,8 @@ func TestGetArtifactDisplayName(t *testing.T) {
 		{homeDir + "/.asdf/plugins/python", "~/.asdf/plugins/python"},
 		{homeDir + "/Applications/Test.app", "Test"},
 		{homeDir + "/custom/path", "~/custom/path"},
+		{"vscode_extension:golang.go", "golang.go"},
+		{"vscode_extension:cursor:golang.go", "golang.go"},
 	}
 
 	for _, test := range tests {
@@ -1,6 +1,9 @@
 package installer
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -8,95 +11,399 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cdzombak/mac-install/internal/config"
+	"gopkg.in/yaml.v3"
 )
 
 type Installer struct {
-	workDir string
+	workDir    string
+	stdout     io.Writer
+	stderr     io.Writer
+	prefetcher *Prefetcher
+
+	// log, if set, receives a copy of all command output along with a record of
+	// each step and command run
+	log io.Writer
+
+	// stepTimeout limits each install and configure step unless the step sets
+	// its own timeout; zero means no limit
+	stepTimeout time.Duration
+
+	// stderrTail, if set, keeps the end of command stderr for a StepError
+	stderrTail *lineTail
+
+	// onFailure, if set, is consulted when a step fails
+	onFailure FailureHandler
+
+	// extensions caches the extensions each editor CLI lists, and is shared by
+	// copies of the installer
+	extensions *extensionCache
 }
 
+// FailureHandler is called when an install or configure step fails, unless the
+// run was cancelled or the failure is ignored, and returns true to run the step
+// again
+type FailureHandler func(ctx context.Context, err *StepError) bool
+
+// commandKillDelay is how long a cancelled command has to exit after SIGTERM
+// before it and its descendants are killed
+const commandKillDelay = 5 * time.Second
+
+// commandWaitDelay bounds how long a cancelled command's output is drained
+// before its pipes are closed, in case a surviving child still holds them. It
+// outlasts commandKillDelay, so descendants are killed while they can still be
+// found through the command.
+const commandWaitDelay = commandKillDelay + 2*time.Second
+
 func New(workDir string) *Installer {
 	return &Installer{
-		workDir: workDir,
+		workDir:    workDir,
+		stdout:     os.Stdout,
+		stderr:     os.Stderr,
+		extensions: newExtensionCache(),
 	}
 }
 
-func (i *Installer) Install(installSteps []map[string]string, artifactPath string) error {
+// WithOutput returns a copy of the installer that writes all command output,
+// stdout and stderr alike, to w
+func (i *Installer) WithOutput(w io.Writer) *Installer {
+	installer := *i
+	installer.stdout = &syncWriter{w: w}
+	installer.stderr = installer.stdout
+	return &installer
+}
+
+// SetLog makes the installer copy all command output, and a record of each step
+// and command with its exit code and duration, to w
+func (i *Installer) SetLog(w io.Writer) {
+	if w == nil {
+		i.log = nil
+		return
+	}
+	i.log = &syncWriter{w: w}
+}
+
+// WithLog returns a copy of the installer that records to w instead
+func (i *Installer) WithLog(w io.Writer) *Installer {
+	installer := *i
+	installer.SetLog(w)
+	return &installer
+}
+
+// WithFailureHandler returns a copy of the installer that consults handler when
+// a step fails
+func (i *Installer) WithFailureHandler(handler FailureHandler) *Installer {
+	installer := *i
+	installer.onFailure = handler
+	return &installer
+}
+
+// SetStepTimeout sets the default time limit for each install and configure step
+func (i *Installer) SetStepTimeout(timeout time.Duration) {
+	i.stepTimeout = timeout
+}
+
+func (i *Installer) Install(ctx context.Context, installSteps []map[string]string, artifactPath string) error {
 	for _, step := range installSteps {
-		// Check for archive installation which requires special handling
-		if archiveURL, hasArchive := step["archive"]; hasArchive {
-			fileName, hasFile := step["file"]
-			if err := i.installFromArchive(archiveURL, fileName, hasFile, artifactPath); err != nil {
-				return fmt.Errorf("archive installation failed: %w", err)
-			}
-			continue
+		timeout, err := i.timeoutForStep(step)
+		if err != nil {
+			return err
 		}
+		for {
+			i.logStep("install", step)
 
-		// Check for download installation which requires special handling
-		if downloadURL, hasDL := step["dl"]; hasDL {
-			// Ensure parent directory exists
-			if err := os.MkdirAll(filepath.Dir(artifactPath), 0755); err != nil {
-				return fmt.Errorf("failed to create directory for download: %w", err)
+			stepInstaller, stderr := i.withStderrTail()
+			start := time.Now()
+			err := runWithTimeout(ctx, timeout, func(ctx context.Context) error {
+				return stepInstaller.installStep(ctx, step, artifactPath)
+			})
+			if err == nil {
+				break
 			}
-			if _, err := i.downloadFile(downloadURL, artifactPath); err != nil {
-				return fmt.Errorf("download installation failed: %w", err)
+
+			method, value := primaryMethod(step)
+			stepErr := newStepError("install", method, value, err, stderr, time.Since(start))
+			if !i.retryFailedStep(ctx, stepErr) {
+				return stepErr
 			}
-			continue
 		}
+	}
+	return nil
+}
 
-		// Handle regular installation methods
-		for method, value := range step {
-			if err := i.executeInstallStep(method, value); err != nil {
-				return fmt.Errorf("installation step %s %s failed: %w", method, value, err)
-			}
+// retryFailedStep reports whether the failure handler, if any, asks for a
+// failed step to be run again
+func (i *Installer) retryFailedStep(ctx context.Context, err *StepError) bool {
+	if i.onFailure == nil || ctx.Err() != nil {
+		return false
+	}
+	return i.onFailure(ctx, err)
+}
+
+// withStderrTail returns a copy of the installer that also keeps the last lines
+// of command stderr in the returned tail, for reporting a failed step
+func (i *Installer) withStderrTail() (*Installer, *lineTail) {
+	installer := *i
+	installer.stderrTail = newLineTail(stepErrorStderrLines)
+	return &installer, installer.stderrTail
+}
+
+func (i *Installer) installStep(ctx context.Context, step map[string]string, artifactPath string) error {
+	// Check for GitHub release installation, which resolves an asset and then
+	// installs it like an archive, package, or plain download
+	if repo, hasRelease := step["github_release"]; hasRelease {
+		opts, err := archiveOptionsFromStep(step)
+		if err != nil {
+			return fmt.Errorf("github release installation failed: %w", err)
+		}
+		if err := i.installFromGitHubRelease(ctx, repo, step["asset"], step["tag"], step["choices"], opts, artifactPath); err != nil {
+			return fmt.Errorf("github release installation failed: %w", err)
+		}
+		return nil
+	}
+
+	// Check for package installation, which may also use 'archive' to locate the package
+	if pkgSource, hasPkg := step["pkg"]; hasPkg {
+		if trustOptionsFromStep(step).isSet() {
+			return fmt.Errorf("pkg installation failed: %w", errTrustForPkg)
+		}
+		archiveURL, hasArchive := step["archive"]
+		var innerPaths []string
+		if inner, hasInner := step["inner"]; hasInner {
+			innerPaths = stepValueList(inner)
+		}
+		if err := i.installPkg(ctx, pkgSource, archiveURL, hasArchive, innerPaths, step["choices"], artifactPath); err != nil {
+			return fmt.Errorf("pkg installation failed: %w", err)
+		}
+		return nil
+	}
+
+	// Check for archive installation which requires special handling
+	if archiveURL, hasArchive := step["archive"]; hasArchive {
+		opts, err := archiveOptionsFromStep(step)
+		if err != nil {
+			return fmt.Errorf("archive installation failed: %w", err)
+		}
+		if err := i.installFromArchive(ctx, archiveURL, opts, artifactPath); err != nil {
+			return fmt.Errorf("archive installation failed: %w", err)
+		}
+		return nil
+	}
+
+	// Editor extensions may name the editor's CLI with 'editor'
+	if extension, hasExtension := step["vscode_extension"]; hasExtension {
+		if err := i.installVSCodeExtension(ctx, extension, step); err != nil {
+			return fmt.Errorf("extension installation failed: %w", err)
+		}
+		return nil
+	}
+
+	// Runtimes may name their version manager, plugin and whether to set them globally
+	if runtime, hasRuntime := step["runtime"]; hasRuntime {
+		if err := i.installRuntime(ctx, runtime, step); err != nil {
+			return fmt.Errorf("runtime installation failed: %w", err)
+		}
+		return nil
+	}
+
+	// Check for download installation which requires special handling
+	if downloadURL, hasDL := step["dl"]; hasDL {
+		if err := i.downloadToArtifact(ctx, downloadURL, artifactPath); err != nil {
+			return fmt.Errorf("download installation failed: %w", err)
+		}
+		if err := i.applyTrust(ctx, artifactPath, trustOptionsFromStep(step)); err != nil {
+			// Don't leave a download that failed verification installed
+			_ = os.RemoveAll(artifactPath)
+			return fmt.Errorf("download installation failed: %w", err)
+		}
+		return nil
+	}
+
+	// Handle regular installation methods
+	for method, value := range step {
+		if method == stepTimeoutKey {
+			continue
+		}
+		if err := i.executeInstallStep(ctx, method, value); err != nil {
+			return fmt.Errorf("installation step %s %s failed: %w", method, value, err)
 		}
 	}
 	return nil
 }
 
-func (i *Installer) Configure(configSteps []map[string]string) error {
+func (i *Installer) Configure(ctx context.Context, configSteps []map[string]string) error {
 	ignoreErrors := false
 
 	for _, step := range configSteps {
+		timeout, err := i.timeoutForStep(step)
+		if err != nil {
+			return err
+		}
+		i.logStep("configure", step)
+		params := configParamKeys(step)
+
 		for method, value := range step {
 			if method == "ignore_errors" {
 				ignoreErrors = strings.ToLower(value) == "true"
 				continue
 			}
+			if method == stepTimeoutKey || params[method] {
+				continue
+			}
+
+			for {
+				stepInstaller, stderr := i.withStderrTail()
+				start := time.Now()
+				err := runWithTimeout(ctx, timeout, func(ctx context.Context) error {
+					return stepInstaller.executeConfigStep(ctx, method, value, step)
+				})
+				if err == nil {
+					break
+				}
+				if ignoreErrors && ctx.Err() == nil {
+					fmt.Fprintf(i.stdout, "Warning: configuration step %s failed (ignored): %v\n", method, err)
+					break
+				}
 
-			if err := i.executeConfigStep(method, value); err != nil {
-				if ignoreErrors {
-					fmt.Printf("Warning: configuration step %s failed (ignored): %v\n", method, err)
-					continue
+				err = fmt.Errorf("configuration step %s failed: %w", method, err)
+				stepErr := newStepError("configure", method, value, err, stderr, time.Since(start))
+				if !i.retryFailedStep(ctx, stepErr) {
+					return stepErr
 				}
-				return fmt.Errorf("configuration step %s failed: %w", method, err)
+				i.logStep("configure", step)
 			}
 		}
 	}
 	return nil
 }
 
-func (i *Installer) executeInstallStep(method, value string) error {
+// configStepParams lists the parameters accompanying configure methods that take
+// more than one value; they are passed to the method rather than run themselves
+var configStepParams = map[string][]string{
+	"defaults":   {"key", "type", "value", "current_host", "kill"},
+	"link":       {"dest"},
+	"copy":       {"dest", "mode"},
+	"template":   {"dest", "mode", "vars"},
+	"launchd":    {"program", "interval", "calendar", "keep_alive", "run_at_load", "env", "log"},
+	"login_item": {"hidden"},
+	"handlers":   {"extensions", "utis", "schemes", "role"},
+	"dock":       {"folders", "remove_others"},
+}
+
+// configParamKeys returns the parameter keys of the configure methods in step
+func configParamKeys(step map[string]string) map[string]bool {
+	params := make(map[string]bool)
+	for method := range step {
+		for _, param := range configStepParams[method] {
+			params[param] = true
+		}
+	}
+	return params
+}
+
+// installStepParams are install step keys that accompany a method rather than
+// name one
+var installStepParams = map[string]bool{
+	stepTimeoutKey:     true,
+	"file":             true,
+	"inner":            true,
+	"dest":             true,
+	"strip_components": true,
+	"rename":           true,
+	"strip_quarantine": true,
+	"team_id":          true,
+	"asset":            true,
+	"tag":              true,
+	"choices":          true,
+	"editor":           true,
+	"manager":          true,
+	"global":           true,
+	"plugin":           true,
+}
+
+// InstallStepMethods returns the install methods named in step, leaving out the
+// parameters that accompany them
+func InstallStepMethods(step map[string]string) []string {
+	methods := make([]string, 0, len(step))
+	for key := range step {
+		if !installStepParams[key] {
+			methods = append(methods, key)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// stepTimeoutKey may accompany any install or configure step to set its time
+// limit, as a Go duration such as "90s" or "15m"
+const stepTimeoutKey = "timeout"
+
+// timeoutForStep returns the step's own time limit, or the installer default
+func (i *Installer) timeoutForStep(step map[string]string) (time.Duration, error) {
+	value, ok := step[stepTimeoutKey]
+	if !ok {
+		return i.stepTimeout, nil
+	}
+	timeout, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid timeout '%s': must be a positive duration such as 90s or 15m", value)
+	}
+	return timeout, nil
+}
+
+// runWithTimeout runs fn with ctx limited to timeout, if one is set, and
+// reports a step that ran out of time as such rather than as a killed command
+func runWithTimeout(ctx context.Context, timeout time.Duration, fn func(context.Context) error) error {
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+
+	stepCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := fn(stepCtx)
+	if err != nil && ctx.Err() == nil && stepCtx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("step timed out after %s: %w", timeout, err)
+	}
+	return err
+}
+
+func (i *Installer) executeInstallStep(ctx context.Context, method, value string) error {
 	switch method {
 	case "brew":
-		return i.runCommand("brew", "install", value)
+		return i.runCommand(ctx, "brew", "install", value)
 	case "cask":
-		return i.runCommand("brew", "install", "--cask", value)
+		return i.runCommand(ctx, "brew", "install", "--cask", value)
 	case "mas":
 		appID := i.extractAppStoreID(value)
-		return i.runCommand("mas", "install", appID)
+		return i.runCommand(ctx, "mas", "install", appID)
 	case "npm":
-		return i.runCommand("/opt/homebrew/bin/npm", "install", "-g", value)
+		return i.runCommand(ctx, "/opt/homebrew/bin/npm", "install", "-g", value)
 	case "gem":
-		return i.runCommand("brew", "gem", "install", value)
+		return i.runCommand(ctx, "brew", "gem", "install", value)
 	case "gomod":
-		return i.runCommand("brew", "gomod", value)
+		return i.runCommand(ctx, "brew", "gomod", value)
 	case "pipx":
-		return i.runCommand("/opt/homebrew/bin/pipx", "install", value)
+		return i.runCommand(ctx, "/opt/homebrew/bin/pipx", "install", value)
+	case "cargo":
+		return i.installCargo(ctx, value)
+	case "go_install":
+		return i.installGo(ctx, value)
+	case "uv_tool":
+		return i.installUVTool(ctx, value)
+	case "pip_user":
+		return i.installPipUser(ctx, value)
 	case "run":
-		return i.runShellCommand(value)
+		return i.runShellCommand(ctx, value)
 	case "script":
-		return i.runScript(value)
+		return i.runScript(ctx, value)
 	case "archive":
 		return fmt.Errorf("archive installation requires special handling with 'file' parameter")
 	default:
@@ -104,38 +411,195 @@ func (i *Installer) executeInstallStep(method, value string) error {
 	}
 }
 
-func (i *Installer) executeConfigStep(method, value string) error {
+// InstallBrewPackages installs several formulae, or casks, with a single brew
+// invocation so Homebrew's startup and auto-update cost is paid once
+func (i *Installer) InstallBrewPackages(ctx context.Context, packages []string, cask bool) error {
+	args := []string{"install"}
+	if cask {
+		args = append(args, "--cask")
+	}
+	return i.runCommand(ctx, "brew", append(args, packages...)...)
+}
+
+func (i *Installer) executeConfigStep(ctx context.Context, method, value string, step map[string]string) error {
 	switch method {
 	case "run":
-		return i.runShellCommand(value)
+		return i.runShellCommand(ctx, value)
 	case "script":
-		return i.runScript(value)
+		return i.runScript(ctx, value)
+	case "defaults":
+		return i.configureDefaults(ctx, value, step)
+	case "link":
+		return i.configureLink(value, step)
+	case "copy":
+		return i.configureCopy(ctx, value, step)
+	case "template":
+		return i.configureTemplate(ctx, value, step)
+	case "launchd":
+		return i.configureLaunchAgent(ctx, value, step)
+	case "login_item":
+		return i.configureLoginItem(ctx, value, step)
+	case "service":
+		return i.configureService(ctx, value)
+	case "handlers":
+		return i.configureHandlers(ctx, value, step)
+	case "dock":
+		return i.configureDock(ctx, value, step)
 	default:
 		return fmt.Errorf("unknown configuration method: %s", method)
 	}
 }
 
-func (i *Installer) runCommand(name string, args ...string) error {
-	cmd := exec.Command(name, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+func (i *Installer) runCommand(ctx context.Context, name string, args ...string) error {
+	return i.run(i.command(ctx, name, args...))
 }
 
-func (i *Installer) runShellCommand(command string) error {
-	cmd := exec.Command("sh", "-c", command)
+func (i *Installer) runShellCommand(ctx context.Context, command string) error {
+	cmd := i.command(ctx, "sh", "-c", command)
 	cmd.Dir = i.workDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return i.run(cmd)
 }
 
-func (i *Installer) runScript(scriptPath string) error {
-	cmd := exec.Command("sh", scriptPath)
+func (i *Installer) runScript(ctx context.Context, scriptPath string) error {
+	cmd := i.command(ctx, "sh", scriptPath)
 	cmd.Dir = i.workDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return i.run(cmd)
+}
+
+// commandOutput runs a command like runCommand, but returns its combined
+// output, recording it in the log rather than showing it
+func (i *Installer) commandOutput(ctx context.Context, name string, args ...string) ([]byte, error) {
+	cmd := i.command(ctx, name, args...)
+	var output bytes.Buffer
+	writers := []io.Writer{&output}
+	if i.log != nil {
+		writers = append(writers, i.log)
+	}
+	cmd.Stdout = combineWriters(writers)
+	cmd.Stderr = cmd.Stdout
+	err := i.run(cmd)
+	return output.Bytes(), err
+}
+
+// printf reports progress to the installer's output, and records it in the log
+func (i *Installer) printf(format string, args ...any) {
+	fmt.Fprintf(i.stdout, format, args...)
+	if i.log != nil {
+		fmt.Fprintf(i.log, format, args...)
+	}
+}
+
+// run runs cmd, recording its command line, exit code and duration in the log
+func (i *Installer) run(cmd *exec.Cmd) error {
+	if i.log == nil {
+		return cmd.Run()
+	}
+
+	if cmd.Dir != "" {
+		fmt.Fprintf(i.log, "$ %s  (in %s)\n", strings.Join(cmd.Args, " "), cmd.Dir)
+	} else {
+		fmt.Fprintf(i.log, "$ %s\n", strings.Join(cmd.Args, " "))
+	}
+
+	start := time.Now()
+	err := cmd.Run()
+	duration := time.Since(start).Round(time.Millisecond)
+
+	if cmd.ProcessState == nil {
+		fmt.Fprintf(i.log, "[failed to start after %s: %v]\n", duration, err)
+	} else {
+		fmt.Fprintf(i.log, "[exit %d after %s]\n", cmd.ProcessState.ExitCode(), duration)
+	}
+	return err
+}
+
+// logStep records the start of an install or configure step in the log
+func (i *Installer) logStep(phase string, step map[string]string) {
+	if i.log == nil {
+		return
+	}
+
+	keys := make([]string, 0, len(step))
+	for key := range step {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fields := make([]string, len(keys))
+	for idx, key := range keys {
+		fields[idx] = fmt.Sprintf("%s=%q", key, step[key])
+	}
+	fmt.Fprintf(i.log, "\n-- %s step %s (%s)\n", phase, strings.Join(fields, " "), time.Now().Format(time.TimeOnly))
+}
+
+// command prepares a command writing to the installer's output, which is killed
+// along with any processes it started if ctx is cancelled before it completes
+func (i *Installer) command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	stdout, stderr := []io.Writer{i.stdout}, []io.Writer{i.stderr}
+	if i.log != nil {
+		stdout = append(stdout, i.log)
+		stderr = append(stderr, i.log)
+	}
+	if i.stderrTail != nil {
+		stderr = append(stderr, i.stderrTail)
+	}
+	cmd.Stdout = combineWriters(stdout)
+	cmd.Stderr = combineWriters(stderr)
+	cmd.Cancel = func() error {
+		// SIGTERM first, since sudo relays it to the command it runs as root,
+		// which can't be signalled directly
+		pid := cmd.Process.Pid
+		signalDescendants(pid, syscall.SIGTERM)
+		time.AfterFunc(commandKillDelay, func() {
+			if cmd.Process.Signal(syscall.Signal(0)) == nil {
+				signalDescendants(pid, syscall.SIGKILL)
+				_ = cmd.Process.Kill()
+			}
+		})
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = commandWaitDelay
+	return cmd
+}
+
+// combineWriters returns a writer copying to all of writers. A lone writer is
+// returned as is, so a command writing only to the terminal still sees one.
+func combineWriters(writers []io.Writer) io.Writer {
+	if len(writers) == 1 {
+		return writers[0]
+	}
+	return io.MultiWriter(writers...)
+}
+
+// syncWriter serializes writes to w, since a command's stdout and stderr are
+// copied by separate goroutines and may share a destination
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// signalDescendants sends sig to all processes descended from pid. Commands stay
+// in our process group so they can prompt on the terminal (e.g. sudo), which
+// means they can't be signalled as a group; instead the process tree is walked
+// with pgrep.
+func signalDescendants(pid int, sig syscall.Signal) {
+	output, err := exec.Command("pgrep", "-P", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return
+	}
+	for _, field := range strings.Fields(string(output)) {
+		if child, err := strconv.Atoi(field); err == nil {
+			signalDescendants(child, sig)
+			_ = syscall.Kill(child, sig)
+		}
+	}
 }
 
 // extractAppStoreID extracts the app ID from either a raw ID or an App Store URL
@@ -158,7 +622,35 @@ func (i *Installer) extractAppStoreID(value string) string {
 	return value
 }
 
-func (i *Installer) ArtifactExists(artifactPath string) bool {
+// stepValueList returns the items of a step value given as a YAML sequence, or
+// the value itself as a single item
+func stepValueList(value string) []string {
+	if items, ok := stepSequence(value); ok {
+		return items
+	}
+	return []string{value}
+}
+
+// stepSequence returns the items of a step value the config flattened from a
+// YAML sequence. Other values aren't decoded, so a scalar that happens to be
+// valid YAML, such as "[x]", stays a single value.
+func stepSequence(value string) ([]string, bool) {
+	if !strings.HasPrefix(value, config.StepListMarker) {
+		return nil, false
+	}
+	var items []string
+	if err := yaml.Unmarshal([]byte(value), &items); err != nil || len(items) == 0 {
+		return nil, false
+	}
+	return items, true
+}
+
+func (i *Installer) ArtifactExists(ctx context.Context, artifactPath string) bool {
+	// Editor extensions are checked with the editor's CLI rather than a path
+	if cli, id, ok := parseExtensionArtifact(artifactPath); ok {
+		return i.extensions.installed(ctx, cli, id)
+	}
+
 	// If the path contains asterisks, treat it as a wildcard pattern
 	if strings.Contains(artifactPath, "*") {
 		matches, err := filepath.Glob(artifactPath)
@@ -174,8 +666,8 @@ func (i *Installer) ArtifactExists(artifactPath string) bool {
 	return err == nil
 }
 
-func (i *Installer) GetBrewCaveats(packageName string) (string, error) {
-	cmd := exec.Command("brew", "caveats", packageName)
+func (i *Installer) GetBrewCaveats(ctx context.Context, packageName string) (string, error) {
+	cmd := exec.CommandContext(ctx, "brew", "caveats", packageName)
 	output, err := cmd.Output()
 	if err != nil {
 		return "", nil
@@ -189,69 +681,259 @@ func (i *Installer) GetBrewCaveats(packageName string) (string, error) {
 	return caveats, nil
 }
 
-func (i *Installer) installFromArchive(archiveURL, fileName string, hasFile bool, artifactPath string) error {
+// archiveOptions controls which extracted archive contents are installed, and where
+type archiveOptions struct {
+	innerPaths      []string
+	files           []string
+	dest            string
+	stripComponents int
+	rename          string
+	trust           trustOptions
+}
+
+func archiveOptionsFromStep(step map[string]string) (archiveOptions, error) {
+	var opts archiveOptions
+	if inner, hasInner := step["inner"]; hasInner {
+		opts.innerPaths = stepValueList(inner)
+	}
+	if fileValue, hasFile := step["file"]; hasFile {
+		opts.files = stepValueList(fileValue)
+	}
+	opts.dest = step["dest"]
+	opts.rename = step["rename"]
+	opts.trust = trustOptionsFromStep(step)
+
+	if value, ok := step["strip_components"]; ok {
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("invalid strip_components '%s': must be a non-negative integer", value)
+		}
+		opts.stripComponents = n
+	}
+
+	if opts.rename != "" && len(opts.files) != 1 {
+		return opts, fmt.Errorf("'rename' requires exactly one 'file'")
+	}
+	return opts, nil
+}
+
+func (i *Installer) installFromArchive(ctx context.Context, archiveURL string, opts archiveOptions, artifactPath string) error {
+	extractDir, cleanup, err := i.prepareArchive(ctx, archiveURL, opts.innerPaths)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if opts.stripComponents > 0 {
+		extractDir, err = stripComponents(extractDir, opts.stripComponents)
+		if err != nil {
+			return fmt.Errorf("failed to strip leading path components: %w", err)
+		}
+	}
+
+	// Install to the directory containing the artifact unless told otherwise
+	destDir := opts.dest
+	if destDir == "" {
+		destDir = filepath.Dir(artifactPath)
+	}
+	destDir = i.resolveLocalPath(destDir)
+
+	// Create destination directory if it doesn't exist
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return fmt.Errorf("failed to create destination directory '%s': %w", destDir, err)
+	}
+
+	if len(opts.files) == 0 {
+		// Apps are checked while still in the extraction directory, so one
+		// that fails verification is never installed
+		if err := i.applyArchiveTrust(ctx, extractDir, opts.trust); err != nil {
+			return err
+		}
+
+		// Copy all files from extraction directory to destination
+		if err := i.copyDirectoryContents(ctx, extractDir, destDir); err != nil {
+			return fmt.Errorf("failed to copy archive contents to '%s': %w", destDir, err)
+		}
+		return nil
+	}
+
+	for _, pattern := range opts.files {
+		// Find the specified files in the extracted contents
+		sourcePaths, err := i.findFilesInDirectory(extractDir, pattern)
+		if err != nil {
+			return fmt.Errorf("failed to find file '%s' in archive: %w", pattern, err)
+		}
+		if opts.rename != "" && len(sourcePaths) > 1 {
+			return fmt.Errorf("'rename' requires '%s' to match exactly one file, but it matched %d", pattern, len(sourcePaths))
+		}
+
+		for _, sourcePath := range sourcePaths {
+			destName := filepath.Base(sourcePath)
+			if opts.rename != "" {
+				destName = opts.rename
+			}
+			destPath := filepath.Join(destDir, destName)
+
+			// Each selected file is checked, whatever it is, since codesign
+			// verifies bare binaries as well as bundles
+			if err := i.applyTrust(ctx, sourcePath, opts.trust); err != nil {
+				return err
+			}
+
+			// Copy the file/directory to the destination
+			if err := i.copyFileOrDirectory(ctx, sourcePath, destPath); err != nil {
+				return fmt.Errorf("failed to copy '%s' to '%s': %w", sourcePath, destPath, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// stripComponents returns a directory holding the contents of root with the
+// first n leading path components removed, like tar's --strip-components.
+// Files shallower than n components are dropped.
+func stripComponents(root string, n int) (string, error) {
+	strippedDir := root + "-stripped"
+	if err := os.MkdirAll(strippedDir, 0755); err != nil {
+		return "", err
+	}
+
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		parts := strings.Split(rel, string(filepath.Separator))
+		if len(parts) <= n {
+			return nil
+		}
+
+		target := filepath.Join(strippedDir, filepath.Join(parts[n:]...))
+		if _, err := os.Lstat(target); err == nil {
+			return fmt.Errorf("'%s' conflicts with another entry after stripping", rel)
+		}
+		if err := os.Rename(path, target); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return strippedDir, nil
+}
+
+// prepareArchive downloads the archive at archiveURL into a new temporary
+// directory and extracts (or mounts and copies) its contents. If innerPaths are
+// given, each names an archive within the previous layer's contents, which is
+// extracted in turn. It returns the directory holding the innermost extracted
+// contents and a cleanup function that removes the temporary directory; the
+// caller must call cleanup when done.
+func (i *Installer) prepareArchive(ctx context.Context, archiveURL string, innerPaths []string) (string, func(), error) {
 	// Create temporary directory for extraction
 	tempDir, err := os.MkdirTemp("", "mac-install-archive-*")
 	if err != nil {
-		return fmt.Errorf("failed to create temp directory: %w", err)
+		return "", nil, fmt.Errorf("failed to create temp directory: %w", err)
 	}
-	defer func() {
+	cleanup := func() {
 		if err := os.RemoveAll(tempDir); err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: failed to remove temp directory: %v\n", err)
 		}
-	}()
+	}
 
 	// Download the archive
 	archivePath := filepath.Join(tempDir, "archive")
-	actualArchivePath, err := i.downloadFile(archiveURL, archivePath)
+	actualArchivePath, err := i.downloadFile(ctx, archiveURL, archivePath)
 	if err != nil {
-		return fmt.Errorf("failed to download archive: %w", err)
+		cleanup()
+		return "", nil, fmt.Errorf("failed to download archive: %w", err)
 	}
 
 	// Determine archive type and extract/mount
 	extractDir := filepath.Join(tempDir, "extracted")
 	if err := os.MkdirAll(extractDir, 0755); err != nil {
-		return fmt.Errorf("failed to create extraction directory: %w", err)
+		cleanup()
+		return "", nil, fmt.Errorf("failed to create extraction directory: %w", err)
 	}
 
-	if err := i.extractArchive(actualArchivePath, extractDir, archiveURL); err != nil {
-		return fmt.Errorf("failed to extract archive: %w", err)
+	if err := i.extractNestedArchive(ctx, actualArchivePath, extractDir, archiveURL, innerPaths); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to extract archive: %w", err)
 	}
 
-	if hasFile {
-		// Find the specified file in the extracted contents
-		sourcePath, err := i.findFileInDirectory(extractDir, fileName)
-		if err != nil {
-			return fmt.Errorf("failed to find file '%s' in archive: %w", fileName, err)
-		}
+	return extractDir, cleanup, nil
+}
 
-		// Determine destination path (assume /Applications for .app files)
-		destPath := filepath.Join("/Applications", fileName)
+// extractNestedArchive extracts archivePath into extractDir. When innerPaths are
+// given, the outer archive is extracted alongside extractDir instead, and the
+// archive named by innerPaths[0] is located in its contents and extracted
+// recursively with the remaining inner paths.
+func (i *Installer) extractNestedArchive(ctx context.Context, archivePath, extractDir, originalURL string, innerPaths []string) error {
+	if len(innerPaths) == 0 {
+		return i.extractArchive(ctx, archivePath, extractDir, originalURL)
+	}
 
-		// Copy the file/directory to the destination
-		if err := i.copyFileOrDirectory(sourcePath, destPath); err != nil {
-			return fmt.Errorf("failed to copy '%s' to '%s': %w", sourcePath, destPath, err)
-		}
-	} else {
-		// Extract all files to the directory containing the artifact
-		destDir := filepath.Dir(artifactPath)
+	layerDir, err := os.MkdirTemp(filepath.Dir(extractDir), "layer-*")
+	if err != nil {
+		return fmt.Errorf("failed to create extraction directory: %w", err)
+	}
+	if err := i.extractArchive(ctx, archivePath, layerDir, originalURL); err != nil {
+		return err
+	}
 
-		// Create destination directory if it doesn't exist
-		if err := os.MkdirAll(destDir, 0755); err != nil {
-			return fmt.Errorf("failed to create destination directory '%s': %w", destDir, err)
-		}
+	innerMatches, err := i.findFilesInDirectory(layerDir, innerPaths[0])
+	if err != nil {
+		return fmt.Errorf("failed to find inner archive '%s': %w", innerPaths[0], err)
+	}
+	if len(innerMatches) > 1 {
+		return fmt.Errorf("inner archive '%s' matched %d files", innerPaths[0], len(innerMatches))
+	}
 
-		// Copy all files from extraction directory to destination
-		if err := i.copyDirectoryContents(extractDir, destDir); err != nil {
-			return fmt.Errorf("failed to copy archive contents to '%s': %w", destDir, err)
-		}
+	return i.extractNestedArchive(ctx, innerMatches[0], extractDir, innerMatches[0], innerPaths[1:])
+}
+
+// downloadToArtifact downloads url directly to the artifact path, creating the
+// parent directory if needed. The file is always written to exactly the artifact
+// path, whatever name the server suggests.
+func (i *Installer) downloadToArtifact(ctx context.Context, url, artifactPath string) error {
+	if err := os.MkdirAll(filepath.Dir(artifactPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for download: %w", err)
 	}
+	_, err := i.download(ctx, url, artifactPath, false)
+	return err
+}
 
-	return nil
+// downloadFile downloads url next to filepath, naming the file from the
+// response's Content-Disposition or Content-Type (see determineFilepath), and
+// returns the path it was written to
+func (i *Installer) downloadFile(ctx context.Context, url, filepath string) (string, error) {
+	return i.download(ctx, url, filepath, true)
 }
 
-func (i *Installer) downloadFile(url, filepath string) (string, error) {
-	resp, err := http.Get(url)
+// download downloads url to filepath, or, if nameFromResponse is set, to the
+// path determineFilepath chooses from the response
+func (i *Installer) download(ctx context.Context, url, filepath string, nameFromResponse bool) (string, error) {
+	if actualFilepath, ok := i.takePrefetched(ctx, url, filepath, nameFromResponse); ok {
+		return actualFilepath, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -266,7 +948,10 @@ func (i *Installer) downloadFile(url, filepath string) (string, error) {
 	}
 
 	// Determine the correct file extension based on Content-Type or Content-Disposition
-	actualFilepath := i.determineFilepath(filepath, resp)
+	actualFilepath := filepath
+	if nameFromResponse {
+		actualFilepath = i.determineFilepath(filepath, resp)
+	}
 
 	out, err := os.Create(actualFilepath)
 	if err != nil {
@@ -328,7 +1013,7 @@ func (i *Installer) determineFilepath(originalPath string, resp *http.Response)
 	return originalPath
 }
 
-func (i *Installer) extractArchive(archivePath, extractDir, originalURL string) error {
+func (i *Installer) extractArchive(ctx context.Context, archivePath, extractDir, originalURL string) error {
 	// Determine file type from the original URL, fallback to local file path
 	lowerURL := strings.ToLower(originalURL)
 	lowerPath := strings.ToLower(archivePath)
@@ -345,19 +1030,23 @@ func (i *Installer) extractArchive(archivePath, extractDir, originalURL string)
 			return err
 		}
 		defer func() {
-			_ = i.runCommand("hdiutil", "detach", mountPoint)
+			// Detach even when ctx was cancelled, so an interrupted run doesn't
+			// leave the image mounted
+			detachCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+			defer cancel()
+			_ = i.runCommand(detachCtx, "hdiutil", "detach", mountPoint)
 			_ = os.RemoveAll(mountPoint)
 		}()
 
-		if err := i.runCommand("hdiutil", "attach", "-mountpoint", mountPoint, "-nobrowse", "-quiet", archivePath); err != nil {
+		if err := i.runCommand(ctx, "hdiutil", "attach", "-mountpoint", mountPoint, "-nobrowse", "-quiet", archivePath); err != nil {
 			return err
 		}
 
-		return i.runCommand("cp", "-R", mountPoint+"/.", extractDir)
+		return i.runCommand(ctx, "cp", "-R", mountPoint+"/.", extractDir)
 	} else if isZIP {
-		return i.runCommand("unzip", "-q", archivePath, "-d", extractDir)
+		return i.runCommand(ctx, "unzip", "-q", archivePath, "-d", extractDir)
 	} else if isTAR {
-		return i.runCommand("tar", "-xzf", archivePath, "-C", extractDir)
+		return i.runCommand(ctx, "tar", "-xzf", archivePath, "-C", extractDir)
 	} else {
 		return fmt.Errorf("unsupported archive format: unable to determine type from URL '%s' or file '%s'", originalURL, archivePath)
 	}
@@ -387,21 +1076,166 @@ func (i *Installer) findFileInDirectory(dir, fileName string) (string, error) {
 	return foundPath, nil
 }
 
-func (i *Installer) copyFileOrDirectory(src, dest string) error {
+// findFilesInDirectory returns all paths in dir matching pattern. Patterns
+// containing a slash are matched against the path relative to dir; others are
+// matched against base names at any depth. Matched directories are not descended.
+func (i *Installer) findFilesInDirectory(dir, pattern string) ([]string, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	var foundPaths []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+
+		name := d.Name()
+		if strings.Contains(pattern, "/") {
+			if name, err = filepath.Rel(dir, path); err != nil {
+				return err
+			}
+		}
+
+		if matched, _ := filepath.Match(pattern, name); matched {
+			foundPaths = append(foundPaths, path)
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(foundPaths) == 0 {
+		return nil, fmt.Errorf("file not found")
+	}
+
+	return foundPaths, nil
+}
+
+func (i *Installer) copyFileOrDirectory(ctx context.Context, src, dest string) error {
 	srcInfo, err := os.Stat(src)
 	if err != nil {
 		return err
 	}
 
 	if srcInfo.IsDir() {
-		return i.runCommand("cp", "-R", src, dest)
+		return i.runCommand(ctx, "cp", "-R", src, dest)
 	} else {
-		return i.runCommand("cp", src, dest)
+		return i.runCommand(ctx, "cp", src, dest)
+	}
+}
+
+// trustOptions controls Gatekeeper-related handling of apps installed outside
+// Homebrew and the App Store
+type trustOptions struct {
+	stripQuarantine bool
+	teamID          string
+}
+
+func trustOptionsFromStep(step map[string]string) trustOptions {
+	return trustOptions{
+		stripQuarantine: strings.ToLower(step["strip_quarantine"]) == "true",
+		teamID:          strings.TrimSpace(step["team_id"]),
+	}
+}
+
+func (t trustOptions) isSet() bool {
+	return t.stripQuarantine || t.teamID != ""
+}
+
+// errTrustForPkg rejects trust options on package installs, which they can't
+// apply to, rather than installing an unverified package
+var errTrustForPkg = errors.New("'strip_quarantine' and 'team_id' don't apply to .pkg installers")
+
+// applyArchiveTrust applies trust options to an archive installed whole: every
+// file in it has its quarantine attribute removed, and every app bundle in it,
+// however deeply nested, is verified. An archive with no app bundle has nothing
+// a team ID can be checked against, so that is an error; 'file' can name the
+// binaries to verify instead.
+func (i *Installer) applyArchiveTrust(ctx context.Context, extractDir string, trust trustOptions) error {
+	if trust.stripQuarantine {
+		if err := i.applyTrust(ctx, extractDir, trustOptions{stripQuarantine: true}); err != nil {
+			return err
+		}
+	}
+	if trust.teamID == "" {
+		return nil
+	}
+
+	var apps []string
+	err := filepath.WalkDir(extractDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && strings.HasSuffix(d.Name(), ".app") {
+			apps = append(apps, path)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(apps) == 0 {
+		return fmt.Errorf("'team_id' is set, but the archive has no app bundle to verify; name the files to verify with 'file'")
+	}
+	for _, app := range apps {
+		if err := i.applyTrust(ctx, app, trustOptions{teamID: trust.teamID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyTrust removes quarantine attributes from path and/or verifies its code
+// signature and team ID, per the given options
+func (i *Installer) applyTrust(ctx context.Context, path string, trust trustOptions) error {
+	if trust.stripQuarantine {
+		if err := i.runCommand(ctx, "xattr", "-dr", "com.apple.quarantine", path); err != nil {
+			return fmt.Errorf("failed to remove quarantine attribute from '%s': %w", path, err)
+		}
+	}
+
+	if trust.teamID != "" {
+		if err := i.runCommand(ctx, "codesign", "--verify", "--deep", "--strict", path); err != nil {
+			return fmt.Errorf("code signature verification failed for '%s': %w", path, err)
+		}
+
+		// codesign writes signing details to stderr
+		output, err := i.commandOutput(ctx, "codesign", "-dv", "--verbose=2", path)
+		if err != nil {
+			return fmt.Errorf("failed to read code signature for '%s': %w", path, err)
+		}
+
+		teamID := parseTeamIdentifier(string(output))
+		if teamID != trust.teamID {
+			return fmt.Errorf("team ID mismatch for '%s': expected %s, got %s", path, trust.teamID, teamID)
+		}
+	}
+
+	return nil
+}
+
+// parseTeamIdentifier extracts the TeamIdentifier from codesign -dv output,
+// returning "not set" for ad-hoc or unsigned code
+func parseTeamIdentifier(output string) string {
+	matches := regexp.MustCompile(`(?m)^TeamIdentifier=(.+)$`).FindStringSubmatch(output)
+	if len(matches) > 1 {
+		return strings.TrimSpace(matches[1])
 	}
+	return "not set"
 }
 
-func (i *Installer) copyDirectoryContents(src, dest string) error {
+func (i *Installer) copyDirectoryContents(ctx context.Context, src, dest string) error {
 	// Use cp to copy all contents of src directory to dest directory
 	// The /. syntax copies contents of the source directory, not the directory itself
-	return i.runCommand("cp", "-R", src+"/.", dest)
+	return i.runCommand(ctx, "cp", "-R", src+"/.", dest)
 }
@@ -0,0 +1,408 @@
+package installer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os/exec"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultsSetting is a single preference written by a 'defaults' configure step
+type defaultsSetting struct {
+	domain      string
+	key         string
+	value       any
+	currentHost bool
+	kill        []string
+}
+
+func defaultsSettingFromStep(domain string, step map[string]string) (defaultsSetting, error) {
+	setting := defaultsSetting{
+		domain:      strings.TrimSpace(domain),
+		key:         step["key"],
+		currentHost: strings.ToLower(step["current_host"]) == "true",
+	}
+	if setting.domain == "" {
+		return setting, fmt.Errorf("'defaults' requires a domain")
+	}
+	if setting.key == "" {
+		return setting, fmt.Errorf("'defaults' requires 'key'")
+	}
+
+	valueType, hasType := step["type"]
+	if !hasType {
+		return setting, fmt.Errorf("'defaults' requires 'type'")
+	}
+	value, hasValue := step["value"]
+	if !hasValue {
+		return setting, fmt.Errorf("'defaults' requires 'value'")
+	}
+
+	var err error
+	setting.value, err = parseDefaultsValue(valueType, value)
+	if err != nil {
+		return setting, fmt.Errorf("invalid value for %s %s: %w", setting.domain, setting.key, err)
+	}
+
+	if kill, hasKill := step["kill"]; hasKill {
+		setting.kill = stepValueList(kill)
+	}
+	return setting, nil
+}
+
+// parseDefaultsValue converts a step's value to the Go value its plist type
+// decodes to, so it can be compared with the current preference. Arrays and
+// dicts are given in YAML, and their elements keep their YAML types.
+func parseDefaultsValue(valueType, value string) (any, error) {
+	switch strings.ToLower(valueType) {
+	case "string":
+		return value, nil
+	case "bool", "boolean":
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "true", "yes", "1":
+			return true, nil
+		case "false", "no", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("'%s' is not a boolean", value)
+	case "int", "integer":
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not an integer", value)
+		}
+		return n, nil
+	case "float", "real":
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a number", value)
+		}
+		return f, nil
+	case "array":
+		var items []any
+		if err := yaml.Unmarshal([]byte(value), &items); err != nil {
+			return nil, fmt.Errorf("array value must be a YAML sequence: %w", err)
+		}
+		return normalizeDefaultsValue(items)
+	case "dict":
+		var dict map[string]any
+		if err := yaml.Unmarshal([]byte(value), &dict); err != nil {
+			return nil, fmt.Errorf("dict value must be a YAML mapping: %w", err)
+		}
+		return normalizeDefaultsValue(dict)
+	default:
+		return nil, fmt.Errorf("unsupported type '%s' (use string, bool, int, float, array or dict)", valueType)
+	}
+}
+
+// normalizeDefaultsValue converts a decoded YAML value to the types a decoded
+// plist uses, so the two can be compared
+func normalizeDefaultsValue(value any) (any, error) {
+	switch v := value.(type) {
+	case string, bool, int64, float64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case []any:
+		items := make([]any, len(v))
+		for idx, item := range v {
+			normalized, err := normalizeDefaultsValue(item)
+			if err != nil {
+				return nil, err
+			}
+			items[idx] = normalized
+		}
+		return items, nil
+	case map[string]any:
+		dict := make(map[string]any, len(v))
+		for key, item := range v {
+			normalized, err := normalizeDefaultsValue(item)
+			if err != nil {
+				return nil, err
+			}
+			dict[key] = normalized
+		}
+		return dict, nil
+	case nil:
+		return nil, fmt.Errorf("null values are not supported")
+	default:
+		return nil, fmt.Errorf("unsupported value %v", v)
+	}
+}
+
+// configureDefaults writes a preference with 'defaults write' unless it already
+// has the desired value, then restarts the processes listed in 'kill' if it changed
+func (i *Installer) configureDefaults(ctx context.Context, domain string, step map[string]string) error {
+	setting, err := defaultsSettingFromStep(domain, step)
+	if err != nil {
+		return err
+	}
+
+	current, exists, err := i.readDefault(ctx, setting)
+	if err != nil {
+		return err
+	}
+	if exists && reflect.DeepEqual(current, setting.value) {
+		i.printf("%s %s is already %s\n", setting.domain, setting.key, formatDefaultsValue(setting.value))
+		return nil
+	}
+
+	args, err := defaultsWriteArgs(setting)
+	if err != nil {
+		return err
+	}
+	if err := i.runCommand(ctx, "defaults", args...); err != nil {
+		return fmt.Errorf("failed to write %s %s: %w", setting.domain, setting.key, err)
+	}
+
+	if exists {
+		i.printf("Changed %s %s from %s to %s\n", setting.domain, setting.key, formatDefaultsValue(current), formatDefaultsValue(setting.value))
+	} else {
+		i.printf("Set %s %s to %s\n", setting.domain, setting.key, formatDefaultsValue(setting.value))
+	}
+
+	for _, process := range setting.kill {
+		// killall fails if the process isn't running, which is fine
+		_ = i.runCommand(ctx, "killall", process)
+	}
+	return nil
+}
+
+// readDefault returns the current value of the setting's preference, and
+// whether it is set
+func (i *Installer) readDefault(ctx context.Context, setting defaultsSetting) (any, bool, error) {
+	args := defaultsHostArgs(setting)
+	args = append(args, "export", setting.domain, "-")
+
+	output, err := exec.CommandContext(ctx, "defaults", args...).Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, false, ctx.Err()
+		}
+		// The domain doesn't exist yet
+		return nil, false, nil
+	}
+
+	root, err := decodePlist(output)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read %s: %w", setting.domain, err)
+	}
+	dict, ok := root.(map[string]any)
+	if !ok {
+		return nil, false, nil
+	}
+	value, exists := dict[setting.key]
+	return value, exists, nil
+}
+
+func defaultsHostArgs(setting defaultsSetting) []string {
+	if setting.currentHost {
+		return []string{"-currentHost"}
+	}
+	return nil
+}
+
+// defaultsWriteArgs returns the 'defaults' arguments writing the setting. Arrays
+// and dicts are written as plist fragments, so their elements keep their types.
+func defaultsWriteArgs(setting defaultsSetting) ([]string, error) {
+	args := defaultsHostArgs(setting)
+	args = append(args, "write", setting.domain, setting.key)
+
+	switch v := setting.value.(type) {
+	case string:
+		return append(args, "-string", v), nil
+	case bool:
+		return append(args, "-bool", strconv.FormatBool(v)), nil
+	case int64:
+		return append(args, "-int", strconv.FormatInt(v, 10)), nil
+	case float64:
+		return append(args, "-float", strconv.FormatFloat(v, 'g', -1, 64)), nil
+	default:
+		fragment, err := encodePlistValue(v)
+		if err != nil {
+			return nil, err
+		}
+		return append(args, fragment), nil
+	}
+}
+
+// encodePlistValue encodes a value as an XML plist fragment on a single line
+func encodePlistValue(value any) (string, error) {
+	var buf bytes.Buffer
+	if err := writePlistValue(&buf, value, -1); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// writePlistValue writes value as XML plist elements. With depth -1 they are
+// written on one line; otherwise each element of an array or dict is written on
+// its own line, indented with tabs one deeper than depth.
+func writePlistValue(buf *bytes.Buffer, value any, depth int) error {
+	newline := func(depth int) {
+		if depth >= 0 {
+			buf.WriteString("\n" + strings.Repeat("\t", depth))
+		}
+	}
+	child := depth
+	if depth >= 0 {
+		child = depth + 1
+	}
+
+	switch v := value.(type) {
+	case string:
+		buf.WriteString("<string>")
+		_ = xml.EscapeText(buf, []byte(v))
+		buf.WriteString("</string>")
+	case bool:
+		if v {
+			buf.WriteString("<true/>")
+		} else {
+			buf.WriteString("<false/>")
+		}
+	case int64:
+		fmt.Fprintf(buf, "<integer>%d</integer>", v)
+	case float64:
+		fmt.Fprintf(buf, "<real>%s</real>", strconv.FormatFloat(v, 'g', -1, 64))
+	case []any:
+		buf.WriteString("<array>")
+		for _, item := range v {
+			newline(child)
+			if err := writePlistValue(buf, item, child); err != nil {
+				return err
+			}
+		}
+		if len(v) > 0 {
+			newline(depth)
+		}
+		buf.WriteString("</array>")
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		buf.WriteString("<dict>")
+		for _, key := range keys {
+			newline(child)
+			buf.WriteString("<key>")
+			_ = xml.EscapeText(buf, []byte(key))
+			buf.WriteString("</key>")
+			newline(child)
+			if err := writePlistValue(buf, v[key], child); err != nil {
+				return err
+			}
+		}
+		if len(keys) > 0 {
+			newline(depth)
+		}
+		buf.WriteString("</dict>")
+	default:
+		return fmt.Errorf("cannot encode %v as a plist value", v)
+	}
+	return nil
+}
+
+// decodePlist decodes an XML property list, such as 'defaults export' writes,
+// into strings, bools, int64s, float64s, []byte, []any and map[string]any.
+// Dates are decoded as their string form.
+func decodePlist(data []byte) (any, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("invalid property list: %w", err)
+		}
+		if start, ok := token.(xml.StartElement); ok && start.Name.Local != "plist" {
+			return decodePlistElement(decoder, start)
+		}
+	}
+}
+
+func decodePlistElement(decoder *xml.Decoder, start xml.StartElement) (any, error) {
+	switch start.Name.Local {
+	case "dict":
+		dict := make(map[string]any)
+		key := ""
+		for {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch t := token.(type) {
+			case xml.StartElement:
+				if t.Name.Local == "key" {
+					if err := decoder.DecodeElement(&key, &t); err != nil {
+						return nil, err
+					}
+					continue
+				}
+				value, err := decodePlistElement(decoder, t)
+				if err != nil {
+					return nil, err
+				}
+				dict[key] = value
+			case xml.EndElement:
+				return dict, nil
+			}
+		}
+	case "array":
+		items := []any{}
+		for {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch t := token.(type) {
+			case xml.StartElement:
+				value, err := decodePlistElement(decoder, t)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, value)
+			case xml.EndElement:
+				return items, nil
+			}
+		}
+	case "true", "false":
+		if err := decoder.Skip(); err != nil {
+			return nil, err
+		}
+		return start.Name.Local == "true", nil
+	}
+
+	var text string
+	if err := decoder.DecodeElement(&text, &start); err != nil {
+		return nil, err
+	}
+	switch start.Name.Local {
+	case "string", "date":
+		return text, nil
+	case "integer":
+		return strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+	case "real":
+		return strconv.ParseFloat(strings.TrimSpace(text), 64)
+	case "data":
+		return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text), ""))
+	default:
+		return nil, fmt.Errorf("unknown property list element <%s>", start.Name.Local)
+	}
+}
+
+// formatDefaultsValue formats a preference value for reporting
+func formatDefaultsValue(value any) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(encoded)
+}
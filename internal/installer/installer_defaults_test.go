@@ -0,0 +1,280 @@
+package installer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cdzombak/mac-install/internal/config"
+)
+
+// fakeCommands puts shell scripts with the given names first on PATH for the
+// test's duration, so commands unavailable in tests can be simulated
+func fakeCommands(t *testing.T, scripts map[string]string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, script := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestParseDefaultsValue(t *testing.T) {
+	tests := []struct {
+		valueType   string
+		value       string
+		expected    any
+		shouldError bool
+	}{
+		{"string", "hello", "hello", false},
+		{"bool", "true", true, false},
+		{"boolean", "NO", false, false},
+		{"bool", "maybe", nil, true},
+		{"int", "42", int64(42), false},
+		{"integer", "4.2", nil, true},
+		{"float", "0.5", 0.5, false},
+		{"real", "1", 1.0, false},
+		{"array", "- a\n- 1\n- true\n", []any{"a", int64(1), true}, false},
+		{"dict", "name: x\nsize: 2\nnested:\n  - 1.5\n", map[string]any{"name": "x", "size": int64(2), "nested": []any{1.5}}, false},
+		{"array", "not: a list\n", nil, true},
+		{"dict", "- a\n", nil, true},
+		{"array", "- ~\n", nil, true},
+		{"date", "2024-01-01", nil, true},
+	}
+
+	for _, test := range tests {
+		result, err := parseDefaultsValue(test.valueType, test.value)
+		if test.shouldError {
+			if err == nil {
+				t.Errorf("Type %s value %q should error", test.valueType, test.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Type %s value %q should not error: %v", test.valueType, test.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("Type %s value %q: expected %#v, got %#v", test.valueType, test.value, test.expected, result)
+		}
+	}
+}
+
+func TestDefaultsSettingFromStep(t *testing.T) {
+	setting, err := defaultsSettingFromStep("com.apple.dock", map[string]string{
+		"key": "autohide", "type": "bool", "value": "true", "current_host": "true", "kill": config.StepListMarker + "- Dock\n- SystemUIServer\n",
+	})
+	if err != nil {
+		t.Fatalf("Valid step should not error: %v", err)
+	}
+	if setting.domain != "com.apple.dock" || setting.key != "autohide" || setting.value != true || !setting.currentHost {
+		t.Errorf("Unexpected setting: %+v", setting)
+	}
+	if !reflect.DeepEqual(setting.kill, []string{"Dock", "SystemUIServer"}) {
+		t.Errorf("Unexpected kill list: %v", setting.kill)
+	}
+
+	for _, step := range []map[string]string{
+		{"type": "bool", "value": "true"},
+		{"key": "autohide", "value": "true"},
+		{"key": "autohide", "type": "bool"},
+	} {
+		if _, err := defaultsSettingFromStep("com.apple.dock", step); err == nil {
+			t.Errorf("Step %v should error", step)
+		}
+	}
+	if _, err := defaultsSettingFromStep(" ", map[string]string{"key": "k", "type": "string", "value": "v"}); err == nil {
+		t.Error("Step without a domain should error")
+	}
+}
+
+func TestDefaultsWriteArgs(t *testing.T) {
+	tests := []struct {
+		setting  defaultsSetting
+		expected []string
+	}{
+		{
+			defaultsSetting{domain: "d", key: "k", value: "v"},
+			[]string{"write", "d", "k", "-string", "v"},
+		},
+		{
+			defaultsSetting{domain: "d", key: "k", value: false, currentHost: true},
+			[]string{"-currentHost", "write", "d", "k", "-bool", "false"},
+		},
+		{
+			defaultsSetting{domain: "d", key: "k", value: int64(36)},
+			[]string{"write", "d", "k", "-int", "36"},
+		},
+		{
+			defaultsSetting{domain: "d", key: "k", value: 0.25},
+			[]string{"write", "d", "k", "-float", "0.25"},
+		},
+		{
+			defaultsSetting{domain: "d", key: "k", value: []any{"a&b", int64(1)}},
+			[]string{"write", "d", "k", "<array><string>a&amp;b</string><integer>1</integer></array>"},
+		},
+		{
+			defaultsSetting{domain: "d", key: "k", value: map[string]any{"b": true, "a": 1.5}},
+			[]string{"write", "d", "k", "<dict><key>a</key><real>1.5</real><key>b</key><true/></dict>"},
+		},
+	}
+
+	for _, test := range tests {
+		args, err := defaultsWriteArgs(test.setting)
+		if err != nil {
+			t.Errorf("Setting %+v should not error: %v", test.setting, err)
+			continue
+		}
+		if !reflect.DeepEqual(args, test.expected) {
+			t.Errorf("Setting %+v: expected %q, got %q", test.setting, test.expected, args)
+		}
+	}
+}
+
+func TestDecodePlist(t *testing.T) {
+	plist := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>autohide</key>
+	<true/>
+	<key>tilesize</key>
+	<integer>36</integer>
+	<key>delay</key>
+	<real>0.5</real>
+	<key>name</key>
+	<string>a &amp; b</string>
+	<key>empty</key>
+	<array/>
+	<key>apps</key>
+	<array>
+		<dict>
+			<key>label</key>
+			<string>Safari</string>
+			<key>hidden</key>
+			<false/>
+		</dict>
+	</array>
+	<key>blob</key>
+	<data>
+	aGVsbG8=
+	</data>
+	<key>when</key>
+	<date>2024-01-01T00:00:00Z</date>
+</dict>
+</plist>
+`
+
+	result, err := decodePlist([]byte(plist))
+	if err != nil {
+		t.Fatalf("Failed to decode plist: %v", err)
+	}
+
+	expected := map[string]any{
+		"autohide": true,
+		"tilesize": int64(36),
+		"delay":    0.5,
+		"name":     "a & b",
+		"empty":    []any{},
+		"apps":     []any{map[string]any{"label": "Safari", "hidden": false}},
+		"blob":     []byte("hello"),
+		"when":     "2024-01-01T00:00:00Z",
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, result)
+	}
+
+	// Encoding and decoding a value gives the same value back
+	value := map[string]any{"list": []any{"x", int64(2), 2.5, false}, "nested": map[string]any{"k": "v"}}
+	fragment, err := encodePlistValue(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTripped, err := decodePlist([]byte(fragment))
+	if err != nil {
+		t.Fatalf("Failed to decode encoded value: %v", err)
+	}
+	if !reflect.DeepEqual(roundTripped, value) {
+		t.Errorf("Round trip: expected %#v, got %#v", value, roundTripped)
+	}
+
+	if _, err := decodePlist([]byte("<plist><bogus>1</bogus></plist>")); err == nil {
+		t.Error("Unknown element should error")
+	}
+}
+
+func TestConfigureDefaults(t *testing.T) {
+	tempDir := t.TempDir()
+	writes := filepath.Join(tempDir, "writes")
+	kills := filepath.Join(tempDir, "kills")
+	t.Setenv("FAKE_DEFAULTS_WRITES", writes)
+	t.Setenv("FAKE_KILLALL_LOG", kills)
+
+	fakeCommands(t, map[string]string{
+		"defaults": `
+if [ "$1" = "-currentHost" ]; then shift; fi
+case "$1" in
+export) cat <<'EOF'
+<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>autohide</key>
+	<false/>
+	<key>tilesize</key>
+	<integer>36</integer>
+</dict>
+</plist>
+EOF
+;;
+write) echo "$@" >> "$FAKE_DEFAULTS_WRITES" ;;
+esac
+`,
+		"killall": `echo "$1" >> "$FAKE_KILLALL_LOG"; exit 1`,
+	})
+
+	var output bytes.Buffer
+	installer := New(tempDir).WithOutput(&output)
+
+	err := installer.Configure(context.Background(), []map[string]string{
+		{"defaults": "com.apple.dock", "key": "tilesize", "type": "int", "value": "36", "kill": "Dock"},
+		{"defaults": "com.apple.dock", "key": "autohide", "type": "bool", "value": "true", "kill": "Dock"},
+		{"defaults": "com.apple.dock", "key": "orientation", "type": "string", "value": "left", "current_host": "true"},
+	})
+	if err != nil {
+		t.Fatalf("Configure should succeed: %v", err)
+	}
+
+	written, err := os.ReadFile(writes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedWrites := "write com.apple.dock autohide -bool true\nwrite com.apple.dock orientation -string left\n"
+	if string(written) != expectedWrites {
+		t.Errorf("Only differing values should be written; expected %q, got %q", expectedWrites, written)
+	}
+
+	killed, err := os.ReadFile(kills)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(killed) != "Dock\n" {
+		t.Errorf("Processes should be killed only after a change, got %q", killed)
+	}
+
+	for _, expected := range []string{
+		"com.apple.dock tilesize is already 36",
+		"Changed com.apple.dock autohide from false to true",
+		"Set com.apple.dock orientation to \"left\"",
+	} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("Output should contain %q, got:\n%s", expected, output.String())
+		}
+	}
+}
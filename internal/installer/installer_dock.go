@@ -0,0 +1,176 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// dockItem is an app or folder in the Dock, as 'dockutil --list' reports it
+type dockItem struct {
+	label string
+	path  string
+	// section is "persistentApps" or "persistentOthers"
+	section string
+}
+
+// parseDockList parses the output of 'dockutil --list', which has a line per
+// item with its label, URL and section separated by tabs
+func parseDockList(output string) []dockItem {
+	var items []dockItem
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			continue
+		}
+		itemURL, err := url.Parse(fields[1])
+		if err != nil || itemURL.Scheme != "file" {
+			continue
+		}
+		items = append(items, dockItem{
+			label:   fields[0],
+			path:    filepath.Clean(itemURL.Path),
+			section: fields[2],
+		})
+	}
+	return items
+}
+
+// planDock returns the dockutil commands that make the given section of the
+// Dock contain the desired items in order. Other items are left where they
+// are, unless removeOthers is set.
+func planDock(current, desired []dockItem, section string, removeOthers bool) [][]string {
+	var plan [][]string
+	var items []dockItem
+	for _, item := range current {
+		if item.section != section {
+			continue
+		}
+		if removeOthers && dockIndex(desired, item.path) < 0 {
+			plan = append(plan, []string{"--remove", item.label, "--no-restart"})
+			continue
+		}
+		items = append(items, item)
+	}
+
+	sectionName := "apps"
+	if section == "persistentOthers" {
+		sectionName = "others"
+	}
+
+	var prev *dockItem
+	for _, want := range desired {
+		idx := dockIndex(items, want.path)
+		switch {
+		case idx < 0:
+			args := []string{"--add", want.path, "--section", sectionName}
+			insertAt := 0
+			if prev == nil {
+				args = append(args, "--position", "beginning")
+			} else {
+				args = append(args, "--after", prev.label)
+				insertAt = dockIndex(items, prev.path) + 1
+			}
+			plan = append(plan, append(args, "--no-restart"))
+			items = append(items[:insertAt], append([]dockItem{want}, items[insertAt:]...)...)
+			idx = insertAt
+		case prev != nil && idx < dockIndex(items, prev.path):
+			item := items[idx]
+			plan = append(plan, []string{"--move", item.label, "--after", prev.label, "--no-restart"})
+			items = append(items[:idx], items[idx+1:]...)
+			idx = dockIndex(items, prev.path) + 1
+			items = append(items[:idx], append([]dockItem{item}, items[idx:]...)...)
+		}
+		item := items[idx]
+		prev = &item
+	}
+	return plan
+}
+
+func dockIndex(items []dockItem, path string) int {
+	for idx, item := range items {
+		if item.path == path {
+			return idx
+		}
+	}
+	return -1
+}
+
+// configureDock adds the listed apps, and the folders in 'folders', to the Dock
+// in the given order using dockutil, restarting the Dock if anything changed.
+// With 'remove_others: true', items that aren't listed are removed.
+func (i *Installer) configureDock(ctx context.Context, apps string, step map[string]string) error {
+	removeOthers := false
+	if value, ok := step["remove_others"]; ok {
+		var err error
+		if removeOthers, err = strconv.ParseBool(strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("invalid remove_others '%s': must be true or false", value)
+		}
+	}
+
+	var desiredApps []dockItem
+	for _, app := range stepValueList(apps) {
+		if strings.TrimSpace(app) == "" {
+			return fmt.Errorf("'dock' contains an empty app name")
+		}
+		path, err := resolveApp(app)
+		if err != nil {
+			// Otherwise the missing app would be removed from the Dock
+			if removeOthers {
+				return fmt.Errorf("%w; with 'remove_others', every app in 'dock' must be installed", err)
+			}
+			// A layout may list optional apps that weren't installed
+			i.printf("Skipping %s in the Dock, since it isn't installed\n", strings.TrimSpace(app))
+			continue
+		}
+		desiredApps = append(desiredApps, dockItem{label: strings.TrimSuffix(filepath.Base(path), ".app"), path: path})
+	}
+
+	var desiredFolders []dockItem
+	if folders, ok := step["folders"]; ok {
+		for _, folder := range stepValueList(folders) {
+			folder = filepath.Clean(strings.TrimSpace(folder))
+			if !filepath.IsAbs(folder) {
+				return fmt.Errorf("'folders' must be absolute paths, got '%s'", folder)
+			}
+			desiredFolders = append(desiredFolders, dockItem{label: filepath.Base(folder), path: folder})
+		}
+	}
+
+	if _, err := exec.LookPath("dockutil"); err != nil {
+		return fmt.Errorf("'dock' requires dockutil; install it with 'brew install dockutil'")
+	}
+	output, err := exec.CommandContext(ctx, "dockutil", "--list").Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("failed to list Dock items: %w", err)
+	}
+	current := parseDockList(string(output))
+
+	plan := planDock(current, desiredApps, "persistentApps", removeOthers)
+	// Folders are only managed when some are listed, so removeOthers doesn't
+	// empty that side of the Dock in a step that only lists apps
+	if len(desiredFolders) > 0 {
+		plan = append(plan, planDock(current, desiredFolders, "persistentOthers", removeOthers)...)
+	}
+
+	if len(plan) == 0 {
+		i.printf("Dock is up to date\n")
+		return nil
+	}
+	for _, args := range plan {
+		if err := i.runCommand(ctx, "dockutil", args...); err != nil {
+			return fmt.Errorf("failed to update the Dock: %w", err)
+		}
+	}
+	// killall fails if the Dock isn't running, which is fine
+	_ = i.runCommand(ctx, "killall", "Dock")
+	i.printf("Updated the Dock\n")
+	return nil
+}
@@ -0,0 +1,152 @@
+package installer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cdzombak/mac-install/internal/config"
+)
+
+func TestParseDockList(t *testing.T) {
+	output := "Safari\tfile:///Applications/Safari.app/\tpersistentApps\t/Users/me/Library/Preferences/com.apple.dock.plist\tcom.apple.Safari\n" +
+		"Visual Studio Code\tfile:///Applications/Visual%20Studio%20Code.app/\tpersistentApps\t/Users/me/Library/Preferences/com.apple.dock.plist\tcom.microsoft.VSCode\n" +
+		"Downloads\tfile:///Users/me/Downloads/\tpersistentOthers\t/Users/me/Library/Preferences/com.apple.dock.plist\t\n" +
+		"\n"
+
+	expected := []dockItem{
+		{"Safari", "/Applications/Safari.app", "persistentApps"},
+		{"Visual Studio Code", "/Applications/Visual Studio Code.app", "persistentApps"},
+		{"Downloads", "/Users/me/Downloads", "persistentOthers"},
+	}
+	if items := parseDockList(output); !reflect.DeepEqual(items, expected) {
+		t.Errorf("Expected %v, got %v", expected, items)
+	}
+}
+
+func TestPlanDock(t *testing.T) {
+	app := func(name string) dockItem {
+		return dockItem{name, "/Applications/" + name + ".app", "persistentApps"}
+	}
+	current := []dockItem{app("Mail"), app("Safari"), app("Notes"), app("Music"), {"Downloads", "/Users/me/Downloads", "persistentOthers"}}
+
+	tests := []struct {
+		name         string
+		desired      []dockItem
+		removeOthers bool
+		expected     [][]string
+	}{
+		{
+			name:    "in order",
+			desired: []dockItem{app("Safari"), app("Music")},
+		},
+		{
+			name:    "add after previous",
+			desired: []dockItem{app("Safari"), app("Firefox"), app("Notes")},
+			expected: [][]string{
+				{"--add", "/Applications/Firefox.app", "--section", "apps", "--after", "Safari", "--no-restart"},
+			},
+		},
+		{
+			name:    "add first",
+			desired: []dockItem{app("Firefox")},
+			expected: [][]string{
+				{"--add", "/Applications/Firefox.app", "--section", "apps", "--position", "beginning", "--no-restart"},
+			},
+		},
+		{
+			name:    "reorder",
+			desired: []dockItem{app("Notes"), app("Mail"), app("Safari")},
+			expected: [][]string{
+				{"--move", "Mail", "--after", "Notes", "--no-restart"},
+				{"--move", "Safari", "--after", "Mail", "--no-restart"},
+			},
+		},
+		{
+			name:         "remove others",
+			desired:      []dockItem{app("Safari"), app("Music")},
+			removeOthers: true,
+			expected: [][]string{
+				{"--remove", "Mail", "--no-restart"},
+				{"--remove", "Notes", "--no-restart"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		plan := planDock(current, test.desired, "persistentApps", test.removeOthers)
+		if !reflect.DeepEqual(plan, test.expected) {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, plan)
+		}
+	}
+}
+
+func TestConfigureDock(t *testing.T) {
+	apps := t.TempDir()
+	oldDirs := applicationDirs
+	applicationDirs = func() []string { return []string{apps} }
+	t.Cleanup(func() { applicationDirs = oldDirs })
+	for _, app := range []string{"Safari.app", "Firefox.app"} {
+		if err := os.Mkdir(filepath.Join(apps, app), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	calls := filepath.Join(t.TempDir(), "calls")
+	t.Setenv("FAKE_DOCK_CALLS", calls)
+	t.Setenv("FAKE_APPS", apps)
+	fakeCommands(t, map[string]string{
+		"dockutil": `
+if [ "$1" = "--list" ]; then
+	printf 'Safari\tfile://%s/Safari.app/\tpersistentApps\t/dock.plist\n' "$FAKE_APPS"
+	exit 0
+fi
+echo "dockutil $*" >> "$FAKE_DOCK_CALLS"
+`,
+		"killall": `echo "killall $*" >> "$FAKE_DOCK_CALLS"`,
+	})
+
+	var output bytes.Buffer
+	installer := New(t.TempDir()).WithOutput(&output)
+
+	err := installer.Configure(context.Background(), []map[string]string{
+		{"dock": "Safari"},
+		{"dock": config.StepListMarker + "- Safari\n- Firefox\n- Missing\n"},
+	})
+	if err != nil {
+		t.Fatalf("Configure should succeed: %v", err)
+	}
+
+	called, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "dockutil --add " + filepath.Join(apps, "Firefox.app") + " --section apps --after Safari --no-restart\nkillall Dock\n"
+	if string(called) != expected {
+		t.Errorf("Expected calls %q, got %q", expected, called)
+	}
+	for _, message := range []string{
+		"Dock is up to date",
+		"Skipping Missing in the Dock, since it isn't installed",
+		"Updated the Dock",
+	} {
+		if !strings.Contains(output.String(), message) {
+			t.Errorf("Output should contain %q, got:\n%s", message, output.String())
+		}
+	}
+
+	for _, step := range []map[string]string{
+		{"dock": "Safari", "remove_others": "please"},
+		{"dock": "Safari", "folders": "Downloads"},
+		// Removing other items would remove a listed app that can't be found
+		{"dock": config.StepListMarker + "- Safari\n- Missing\n", "remove_others": "true"},
+	} {
+		if err := installer.Configure(context.Background(), []map[string]string{step}); err == nil {
+			t.Errorf("Step %v should error", step)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package installer
+
+import (
+	"errors"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stepErrorStderrLines is how many trailing lines of stderr a StepError keeps
+const stepErrorStderrLines = 20
+
+// StepError reports a failed install or configure step with the details needed
+// to summarize, report or retry it. Its message is that of the wrapped error.
+type StepError struct {
+	// Software is the display name of the software being processed; it is set
+	// by the caller, since the installer only sees steps
+	Software string
+	// Phase is "install" or "configure"
+	Phase  string
+	Method string
+	Value  string
+	// ExitCode is the exit code of the failed command, or -1 if the step failed
+	// without a command exiting (e.g. a download error or killed command)
+	ExitCode int
+	// Stderr holds the last lines of stderr written by the step's commands
+	Stderr   []string
+	Duration time.Duration
+	Err      error
+}
+
+func (e *StepError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// ArtifactMissingError reports software whose installation succeeded but whose
+// artifact still doesn't exist
+type ArtifactMissingError struct {
+	Software string
+	Artifact string
+	// Inferred is set when the artifact was inferred from a package name
+	Inferred bool
+}
+
+func (e *ArtifactMissingError) Error() string {
+	message := "installation completed but artifact " + e.Artifact + " not found"
+	if e.Inferred {
+		message += " (the artifact defaults to a command named after the package; set 'artifact' if the command is named differently)"
+	}
+	return message
+}
+
+func newStepError(phase, method, value string, err error, stderr *lineTail, duration time.Duration) *StepError {
+	exitCode := -1
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	}
+
+	return &StepError{
+		Phase:    phase,
+		Method:   method,
+		Value:    value,
+		ExitCode: exitCode,
+		Stderr:   stderr.Lines(),
+		Duration: duration,
+		Err:      err,
+	}
+}
+
+// primaryMethod returns the method of an install step, along with its value,
+// ignoring parameters such as 'file' that accompany the special methods
+func primaryMethod(step map[string]string) (string, string) {
+	for _, method := range []string{"github_release", "pkg", "archive", "vscode_extension", "runtime", "cargo", "go_install", "uv_tool", "pip_user", "dl"} {
+		if value, ok := step[method]; ok {
+			return method, value
+		}
+	}
+
+	methods := make([]string, 0, len(step))
+	for method := range step {
+		if method != stepTimeoutKey {
+			methods = append(methods, method)
+		}
+	}
+	if len(methods) == 0 {
+		return "", ""
+	}
+	sort.Strings(methods)
+	return methods[0], step[methods[0]]
+}
+
+// lineTail is a writer that keeps the last max lines written to it
+type lineTail struct {
+	mu      sync.Mutex
+	max     int
+	lines   []string
+	partial string
+}
+
+func newLineTail(max int) *lineTail {
+	return &lineTail{max: max}
+}
+
+func (t *lineTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	parts := strings.Split(t.partial+string(p), "\n")
+	t.partial = parts[len(parts)-1]
+	t.lines = append(t.lines, parts[:len(parts)-1]...)
+	if len(t.lines) > t.max {
+		t.lines = t.lines[len(t.lines)-t.max:]
+	}
+	return len(p), nil
+}
+
+// Lines returns the retained lines, including an unterminated final line
+func (t *lineTail) Lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lines := append([]string(nil), t.lines...)
+	if t.partial != "" {
+		lines = append(lines, t.partial)
+	}
+	if len(lines) > t.max {
+		lines = lines[len(lines)-t.max:]
+	}
+	return lines
+}
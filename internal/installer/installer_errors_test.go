@@ -0,0 +1,137 @@
+package installer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestInstallStepError(t *testing.T) {
+	installer := New(t.TempDir())
+
+	installSteps := []map[string]string{
+		{"run": "echo first >&2; echo boom >&2; exit 7"},
+	}
+	err := installer.Install(context.Background(), installSteps, "/nonexistent")
+
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) {
+		t.Fatalf("Expected StepError, got %T: %v", err, err)
+	}
+	if stepErr.Phase != "install" || stepErr.Method != "run" || stepErr.Value != installSteps[0]["run"] {
+		t.Errorf("Unexpected step details: %+v", stepErr)
+	}
+	if stepErr.ExitCode != 7 {
+		t.Errorf("Expected exit code 7, got %d", stepErr.ExitCode)
+	}
+	if !reflect.DeepEqual(stepErr.Stderr, []string{"first", "boom"}) {
+		t.Errorf("Expected stderr [first boom], got %q", stepErr.Stderr)
+	}
+	if stepErr.Duration <= 0 {
+		t.Error("Expected a positive duration")
+	}
+	if !contains(err.Error(), "installation step run") {
+		t.Errorf("StepError should keep the step's message, got: %v", err)
+	}
+}
+
+func TestConfigureStepError(t *testing.T) {
+	installer := New(t.TempDir())
+
+	err := installer.Configure(context.Background(), []map[string]string{{"run": "exit 2"}})
+
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) {
+		t.Fatalf("Expected StepError, got %T: %v", err, err)
+	}
+	if stepErr.Phase != "configure" || stepErr.Method != "run" || stepErr.ExitCode != 2 {
+		t.Errorf("Unexpected step details: %+v", stepErr)
+	}
+}
+
+func TestStepErrorWithoutCommand(t *testing.T) {
+	tempDir := t.TempDir()
+	installer := New(tempDir)
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	installSteps := []map[string]string{{"dl": server.URL + "/missing"}}
+	err := installer.Install(context.Background(), installSteps, filepath.Join(tempDir, "file"))
+
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) {
+		t.Fatalf("Expected StepError, got %T: %v", err, err)
+	}
+	if stepErr.Method != "dl" || stepErr.ExitCode != -1 || len(stepErr.Stderr) != 0 {
+		t.Errorf("Unexpected step details: %+v", stepErr)
+	}
+}
+
+func TestPrimaryMethod(t *testing.T) {
+	tests := []struct {
+		step           map[string]string
+		expectedMethod string
+		expectedValue  string
+	}{
+		{map[string]string{"brew": "wget"}, "brew", "wget"},
+		{map[string]string{"archive": "https://example.com/a.zip", "file": "A.app"}, "archive", "https://example.com/a.zip"},
+		{map[string]string{"pkg": "Tool.pkg", "archive": "https://example.com/a.dmg"}, "pkg", "Tool.pkg"},
+		{map[string]string{"run": "true", "timeout": "1m"}, "run", "true"},
+		{map[string]string{"vscode_extension": "golang.go", "editor": "cursor"}, "vscode_extension", "golang.go"},
+		{map[string]string{"runtime": "python@3.12", "manager": "asdf", "global": "true"}, "runtime", "python@3.12"},
+	}
+
+	for _, test := range tests {
+		method, value := primaryMethod(test.step)
+		if method != test.expectedMethod || value != test.expectedValue {
+			t.Errorf("primaryMethod(%v) = %s, %s; expected %s, %s", test.step, method, value, test.expectedMethod, test.expectedValue)
+		}
+	}
+}
+
+func TestLineTail(t *testing.T) {
+	tail := newLineTail(2)
+
+	_, _ = tail.Write([]byte("one\ntw"))
+	_, _ = tail.Write([]byte("o\nthree\nfou"))
+
+	if lines := tail.Lines(); !reflect.DeepEqual(lines, []string{"three", "fou"}) {
+		t.Errorf("Expected [three fou], got %q", lines)
+	}
+
+	_, _ = tail.Write([]byte("r\n"))
+	if lines := tail.Lines(); !reflect.DeepEqual(lines, []string{"three", "four"}) {
+		t.Errorf("Expected [three four], got %q", lines)
+	}
+}
+
+func TestFailureHandler(t *testing.T) {
+	calls := 0
+	installer := New(t.TempDir()).WithFailureHandler(func(ctx context.Context, err *StepError) bool {
+		calls++
+		return calls < 2
+	})
+
+	err := installer.Install(context.Background(), []map[string]string{{"run": "exit 1"}}, "/nonexistent")
+	if err == nil {
+		t.Fatal("Step should fail once the handler stops retrying")
+	}
+	if calls != 2 {
+		t.Errorf("Expected the handler to be called twice, got %d", calls)
+	}
+
+	// Ignored failures don't reach the handler
+	calls = 0
+	configSteps := []map[string]string{{"ignore_errors": "true"}, {"run": "exit 1"}}
+	if err := installer.Configure(context.Background(), configSteps); err != nil {
+		t.Fatalf("Ignored failure should not error: %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("Handler should not be called for ignored failures, got %d calls", calls)
+	}
+}
@@ -0,0 +1,253 @@
+package installer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// resolveSource returns the absolute path of a file named by a configure step,
+// relative to the config file directory
+func (i *Installer) resolveSource(source string) (string, error) {
+	source = strings.TrimSpace(source)
+	if source == "" {
+		return "", fmt.Errorf("source path is empty")
+	}
+	if !filepath.IsAbs(source) {
+		source = filepath.Join(i.workDir, source)
+	}
+	if _, err := os.Stat(source); err != nil {
+		return "", fmt.Errorf("source '%s' not found: %w", source, err)
+	}
+	return source, nil
+}
+
+// destFromStep returns the required 'dest' parameter of a step placing a file
+func destFromStep(method string, step map[string]string) (string, error) {
+	dest := strings.TrimSpace(step["dest"])
+	if dest == "" {
+		return "", fmt.Errorf("'%s' requires 'dest'", method)
+	}
+	if !filepath.IsAbs(dest) {
+		return "", fmt.Errorf("'dest' must be an absolute path, got '%s'", dest)
+	}
+	return dest, nil
+}
+
+// configureLink makes dest a symlink to a file or directory in the config
+// directory. Anything else already at dest is backed up first.
+func (i *Installer) configureLink(source string, step map[string]string) error {
+	source, err := i.resolveSource(source)
+	if err != nil {
+		return err
+	}
+	dest, err := destFromStep("link", step)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(dest)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	case info.Mode()&os.ModeSymlink != 0 && linksTo(dest, source):
+		i.printf("%s already links to %s\n", dest, source)
+		return nil
+	case info.Mode().IsRegular() && sameContents(dest, source):
+		// A copy of the source needs no backup
+		if err := os.Remove(dest); err != nil {
+			return err
+		}
+	default:
+		if err := i.backUp(dest); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for '%s': %w", dest, err)
+	}
+	if err := os.Symlink(source, dest); err != nil {
+		return fmt.Errorf("failed to link '%s': %w", dest, err)
+	}
+	i.printf("Linked %s to %s\n", dest, source)
+	return nil
+}
+
+// configureCopy copies a file from the config directory to dest, setting its
+// mode to 'mode' or the source's mode. A differing file already at dest is
+// backed up first.
+func (i *Installer) configureCopy(ctx context.Context, source string, step map[string]string) error {
+	source, err := i.resolveSource(source)
+	if err != nil {
+		return err
+	}
+	dest, err := destFromStep("copy", step)
+	if err != nil {
+		return err
+	}
+
+	sourceInfo, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if !sourceInfo.Mode().IsRegular() {
+		return fmt.Errorf("'copy' only supports files, and '%s' is not one", source)
+	}
+
+	mode, err := modeFromStep(step, sourceInfo.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	contents, err := os.ReadFile(source)
+	if err != nil {
+		return err
+	}
+	written, err := i.placeFile(ctx, dest, contents, mode, false)
+	if err != nil {
+		return fmt.Errorf("failed to copy to '%s': %w", dest, err)
+	}
+	if written {
+		i.printf("Copied %s to %s\n", source, dest)
+	}
+	return nil
+}
+
+// placeFile makes dest a file with the given contents and mode, changing only
+// its mode if the contents already match. A differing file is backed up first,
+// after its differences are shown if showDiff is set. It returns whether the
+// contents were written.
+func (i *Installer) placeFile(ctx context.Context, dest string, contents []byte, mode os.FileMode, showDiff bool) (bool, error) {
+	info, err := os.Lstat(dest)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return false, err
+	case info.Mode().IsRegular() && fileHasContents(dest, contents):
+		if info.Mode().Perm() == mode {
+			i.printf("%s is up to date\n", dest)
+			return false, nil
+		}
+		if err := os.Chmod(dest, mode); err != nil {
+			return false, err
+		}
+		i.printf("Changed mode of %s to %04o\n", dest, mode)
+		return false, nil
+	default:
+		if showDiff && info.Mode().IsRegular() {
+			i.showDiff(ctx, dest, contents)
+		}
+		if err := i.backUp(dest); err != nil {
+			return false, err
+		}
+	}
+
+	if err := writeFileAtomically(dest, contents, mode); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// showDiff prints the differences between the file at path and contents
+func (i *Installer) showDiff(ctx context.Context, path string, contents []byte) {
+	cmd := i.command(ctx, "diff", "-u", "-L", path, "-L", path+" (new)", path, "-")
+	cmd.Stdin = bytes.NewReader(contents)
+	// diff exits 1 when the files differ
+	_ = i.run(cmd)
+}
+
+// backUp moves path aside to a timestamped backup next to it, so a local
+// change isn't lost when a configure step replaces it
+func (i *Installer) backUp(path string) error {
+	backup := fmt.Sprintf("%s.backup-%s", path, time.Now().Format("20060102-150405"))
+	for n := 2; ; n++ {
+		if _, err := os.Lstat(backup); os.IsNotExist(err) {
+			break
+		}
+		backup = fmt.Sprintf("%s.backup-%s-%d", path, time.Now().Format("20060102-150405"), n)
+	}
+
+	if err := os.Rename(path, backup); err != nil {
+		return fmt.Errorf("failed to back up '%s': %w", path, err)
+	}
+	i.printf("Backed up %s to %s\n", path, backup)
+	return nil
+}
+
+// writeFileAtomically writes contents to path via a temporary file in the same
+// directory, creating the directory if needed
+func writeFileAtomically(path string, contents []byte, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(contents); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// linksTo reports whether the symlink at path points to target
+func linksTo(path, target string) bool {
+	link, err := os.Readlink(path)
+	if err != nil {
+		return false
+	}
+	if !filepath.IsAbs(link) {
+		link = filepath.Join(filepath.Dir(path), link)
+	}
+	return filepath.Clean(link) == filepath.Clean(target)
+}
+
+// sameContents reports whether two files have the same contents
+func sameContents(a, b string) bool {
+	contents, err := os.ReadFile(b)
+	if err != nil {
+		return false
+	}
+	return fileHasContents(a, contents)
+}
+
+// fileHasContents reports whether the file at path has the given contents
+func fileHasContents(path string, contents []byte) bool {
+	existing, err := os.ReadFile(path)
+	return err == nil && bytes.Equal(existing, contents)
+}
+
+// modeFromStep returns the file mode given by the step's 'mode', or fallback
+func modeFromStep(step map[string]string, fallback os.FileMode) (os.FileMode, error) {
+	value, hasMode := step["mode"]
+	if !hasMode {
+		return fallback, nil
+	}
+	return parseFileMode(value)
+}
+
+// parseFileMode parses an octal file mode such as "0600" or "644"
+func parseFileMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(strings.TrimSpace(value), 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid mode '%s': must be octal permissions such as 0644", value)
+	}
+	return os.FileMode(mode), nil
+}
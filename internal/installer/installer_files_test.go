@@ -0,0 +1,167 @@
+package installer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// backups returns the backups made of path
+func backups(t *testing.T, path string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(path + ".backup-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func TestConfigureLink(t *testing.T) {
+	configDir := t.TempDir()
+	home := t.TempDir()
+	source := filepath.Join(configDir, "dotfiles", "gitconfig")
+	if err := os.MkdirAll(filepath.Dir(source), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(source, []byte("[user]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(home, ".config", "git", "config")
+
+	var output bytes.Buffer
+	installer := New(configDir).WithOutput(&output)
+	step := []map[string]string{{"link": "dotfiles/gitconfig", "dest": dest}}
+
+	if err := installer.Configure(context.Background(), step); err != nil {
+		t.Fatalf("Link should succeed: %v", err)
+	}
+	if !linksTo(dest, source) {
+		t.Fatal("Destination should link to the source, creating parent directories")
+	}
+
+	// Linking again is a no-op
+	output.Reset()
+	if err := installer.Configure(context.Background(), step); err != nil {
+		t.Fatalf("Repeated link should succeed: %v", err)
+	}
+	if !strings.Contains(output.String(), "already links to") || len(backups(t, dest)) != 0 {
+		t.Errorf("Repeated link should change nothing, output: %s", output.String())
+	}
+
+	// A local file is backed up before it is replaced
+	if err := os.Remove(dest); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dest, []byte("local edits\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := installer.Configure(context.Background(), step); err != nil {
+		t.Fatalf("Link over a file should succeed: %v", err)
+	}
+	if !linksTo(dest, source) {
+		t.Error("Differing file should be replaced by the link")
+	}
+	saved := backups(t, dest)
+	if len(saved) != 1 {
+		t.Fatalf("Differing file should be backed up once, found %v", saved)
+	}
+	if contents, _ := os.ReadFile(saved[0]); string(contents) != "local edits\n" {
+		t.Errorf("Backup should hold the local file, got %q", contents)
+	}
+
+	// An identical copy is replaced without a backup
+	if err := os.Remove(dest); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dest, []byte("[user]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := installer.Configure(context.Background(), step); err != nil {
+		t.Fatalf("Link over an identical file should succeed: %v", err)
+	}
+	if !linksTo(dest, source) || len(backups(t, dest)) != 1 {
+		t.Error("Identical file should be replaced by the link without a backup")
+	}
+}
+
+func TestConfigureCopy(t *testing.T) {
+	configDir := t.TempDir()
+	home := t.TempDir()
+	if err := os.WriteFile(filepath.Join(configDir, "ssh_config"), []byte("Host *\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(home, ".ssh", "config")
+
+	var output bytes.Buffer
+	installer := New(configDir).WithOutput(&output)
+	step := []map[string]string{{"copy": "ssh_config", "dest": dest, "mode": "0600"}}
+
+	if err := installer.Configure(context.Background(), step); err != nil {
+		t.Fatalf("Copy should succeed: %v", err)
+	}
+	info, err := os.Stat(dest)
+	if err != nil {
+		t.Fatal("Destination should be created along with its parent directories")
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %04o", info.Mode().Perm())
+	}
+
+	output.Reset()
+	if err := installer.Configure(context.Background(), step); err != nil {
+		t.Fatalf("Repeated copy should succeed: %v", err)
+	}
+	if !strings.Contains(output.String(), "is up to date") || len(backups(t, dest)) != 0 {
+		t.Errorf("Repeated copy should change nothing, output: %s", output.String())
+	}
+
+	// Only the mode is fixed when the contents match
+	if err := os.Chmod(dest, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := installer.Configure(context.Background(), step); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(dest); info.Mode().Perm() != 0600 || len(backups(t, dest)) != 0 {
+		t.Error("Mode should be fixed without a backup")
+	}
+
+	if err := os.WriteFile(dest, []byte("Host local\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := installer.Configure(context.Background(), step); err != nil {
+		t.Fatalf("Copy over a differing file should succeed: %v", err)
+	}
+	if contents, _ := os.ReadFile(dest); string(contents) != "Host *\n" {
+		t.Errorf("Differing file should be replaced, got %q", contents)
+	}
+	if saved := backups(t, dest); len(saved) != 1 {
+		t.Errorf("Differing file should be backed up, found %v", saved)
+	}
+}
+
+func TestConfigureFileValidation(t *testing.T) {
+	configDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(configDir, "file"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(t.TempDir(), "dest")
+
+	tests := []map[string]string{
+		{"link": "missing", "dest": dest},
+		{"link": "file"},
+		{"copy": "file", "dest": "relative/path"},
+		{"copy": "file", "dest": dest, "mode": "rw-r--r--"},
+		{"copy": ".", "dest": dest},
+	}
+
+	installer := New(configDir).WithOutput(&bytes.Buffer{})
+	for _, step := range tests {
+		if err := installer.Configure(context.Background(), []map[string]string{step}); err == nil {
+			t.Errorf("Step %v should error", step)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package installer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// githubAPIBaseURL is the GitHub REST API endpoint; overridden in tests
+var githubAPIBaseURL = "https://api.github.com"
+
+type githubRelease struct {
+	TagName string               `json:"tag_name"`
+	Assets  []githubReleaseAsset `json:"assets"`
+}
+
+type githubReleaseAsset struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// installFromGitHubRelease resolves the release asset matching assetPattern and
+// installs it: archives go through the archive path, packages through the pkg
+// path (with choicesPath, if given), and anything else is downloaded directly to
+// the artifact path.
+func (i *Installer) installFromGitHubRelease(ctx context.Context, repo, assetPattern, tag, choicesPath string, opts archiveOptions, artifactPath string) error {
+	if assetPattern == "" {
+		return fmt.Errorf("github_release requires an 'asset' pattern")
+	}
+
+	release, err := i.fetchGitHubRelease(ctx, repo, tag)
+	if err != nil {
+		return err
+	}
+
+	asset, err := selectReleaseAsset(release, assetPattern)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", repo, release.TagName, err)
+	}
+
+	i.printf("  Using %s from %s %s\n", asset.Name, repo, release.TagName)
+
+	switch {
+	case isArchiveName(asset.Name):
+		return i.installFromArchive(ctx, asset.BrowserDownloadURL, opts, artifactPath)
+	case strings.HasSuffix(strings.ToLower(asset.Name), ".pkg"):
+		if len(opts.innerPaths) > 0 {
+			return fmt.Errorf("'inner' applies only to archive assets, not %s", asset.Name)
+		}
+		if opts.trust.isSet() {
+			return errTrustForPkg
+		}
+		return i.installPkg(ctx, asset.BrowserDownloadURL, "", false, nil, choicesPath, artifactPath)
+	default:
+		if err := i.downloadToArtifact(ctx, asset.BrowserDownloadURL, artifactPath); err != nil {
+			return err
+		}
+		if err := i.applyTrust(ctx, artifactPath, opts.trust); err != nil {
+			_ = os.RemoveAll(artifactPath)
+			return err
+		}
+		// Release assets that aren't archives are almost always bare binaries
+		return os.Chmod(artifactPath, 0755)
+	}
+}
+
+// fetchGitHubRelease queries the GitHub API for the given tag, or for the latest
+// release when tag is empty. GITHUB_TOKEN is used for authentication if set.
+func (i *Installer) fetchGitHubRelease(ctx context.Context, repo, tag string) (*githubRelease, error) {
+	if !regexp.MustCompile(`^[\w.-]+/[\w.-]+$`).MatchString(repo) {
+		return nil, fmt.Errorf("invalid repository '%s': expected owner/repo", repo)
+	}
+
+	url := fmt.Sprintf("%s/repos/%s/releases/latest", githubAPIBaseURL, repo)
+	if tag != "" {
+		url = fmt.Sprintf("%s/repos/%s/releases/tags/%s", githubAPIBaseURL, repo, neturl.PathEscape(tag))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query GitHub releases: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close response body: %v\n", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GitHub release lookup for %s failed with status: %s", repo, resp.Status)
+	}
+
+	var release githubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return nil, fmt.Errorf("failed to decode GitHub release: %w", err)
+	}
+
+	return &release, nil
+}
+
+// selectReleaseAsset returns the single asset whose full name matches pattern
+func selectReleaseAsset(release *githubRelease, pattern string) (githubReleaseAsset, error) {
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return githubReleaseAsset{}, fmt.Errorf("invalid asset pattern '%s': %w", pattern, err)
+	}
+
+	var matches []githubReleaseAsset
+	for _, asset := range release.Assets {
+		if re.MatchString(asset.Name) {
+			matches = append(matches, asset)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return githubReleaseAsset{}, fmt.Errorf("no release asset matches '%s'", pattern)
+	case 1:
+		return matches[0], nil
+	default:
+		names := make([]string, len(matches))
+		for idx, asset := range matches {
+			names[idx] = asset.Name
+		}
+		return githubReleaseAsset{}, fmt.Errorf("multiple release assets match '%s': %s", pattern, strings.Join(names, ", "))
+	}
+}
+
+func isArchiveName(name string) bool {
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".dmg", ".zip", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,215 @@
+package installer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSelectReleaseAsset(t *testing.T) {
+	release := &githubRelease{
+		TagName: "v1.2.3",
+		Assets: []githubReleaseAsset{
+			{Name: "tool_1.2.3_darwin_arm64.tar.gz"},
+			{Name: "tool_1.2.3_darwin_arm64.tar.gz.sha256"},
+			{Name: "tool_1.2.3_darwin_amd64.tar.gz"},
+			{Name: "tool_1.2.3_linux_amd64.tar.gz"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		pattern     string
+		expected    string
+		shouldError bool
+	}{
+		{
+			name:     "single match ignores checksum file",
+			pattern:  "tool_.*_darwin_arm64.tar.gz",
+			expected: "tool_1.2.3_darwin_arm64.tar.gz",
+		},
+		{
+			name:        "multiple matches",
+			pattern:     "tool_.*_darwin_.*.tar.gz",
+			shouldError: true,
+		},
+		{
+			name:        "no match",
+			pattern:     "tool_.*_windows_.*.zip",
+			shouldError: true,
+		},
+		{
+			name:        "invalid pattern",
+			pattern:     "tool_[",
+			shouldError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			asset, err := selectReleaseAsset(release, test.pattern)
+			if test.shouldError {
+				if err == nil {
+					t.Errorf("Expected error for pattern '%s'", test.pattern)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if asset.Name != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, asset.Name)
+			}
+		})
+	}
+}
+
+func TestInstallGitHubReleaseBinary(t *testing.T) {
+	tempDir := t.TempDir()
+	installer := New(tempDir)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/vendor/tool/releases/tags/v2.0.0":
+			_, _ = w.Write([]byte(`{"tag_name": "v2.0.0", "assets": [
+				{"name": "tool-darwin-arm64", "browser_download_url": "` + server.URL + `/download/tool-darwin-arm64"},
+				{"name": "tool-linux-amd64", "browser_download_url": "` + server.URL + `/download/tool-linux-amd64"}
+			]}`))
+		case "/download/tool-darwin-arm64":
+			// GitHub names the asset in Content-Disposition, which must not
+			// override the artifact path
+			w.Header().Set("Content-Disposition", "attachment; filename=tool-darwin-arm64")
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write([]byte("binary content"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	oldBaseURL := githubAPIBaseURL
+	githubAPIBaseURL = server.URL
+	defer func() { githubAPIBaseURL = oldBaseURL }()
+
+	artifact := filepath.Join(tempDir, "bin", "tool")
+	installSteps := []map[string]string{
+		{"github_release": "vendor/tool", "asset": "tool-darwin-arm64", "tag": "v2.0.0"},
+	}
+
+	if err := installer.Install(context.Background(), installSteps, artifact); err != nil {
+		t.Fatalf("Install with github_release should not error: %v", err)
+	}
+
+	info, err := os.Stat(artifact)
+	if err != nil {
+		t.Fatalf("Downloaded binary should exist: %v", err)
+	}
+	if info.Mode().Perm()&0111 == 0 {
+		t.Error("Downloaded binary should be executable")
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "bin", "tool-darwin-arm64")); !os.IsNotExist(err) {
+		t.Error("Binary should not be saved under the asset's name")
+	}
+}
+
+func TestInstallGitHubReleasePkg(t *testing.T) {
+	tempDir := t.TempDir()
+	installer := New(tempDir)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/vendor/tool/releases/latest":
+			_, _ = w.Write([]byte(`{"tag_name": "v1.0.0", "assets": [
+				{"name": "Tool-1.0.0.pkg", "browser_download_url": "` + server.URL + `/download/Tool-1.0.0.pkg"}
+			]}`))
+		case "/download/Tool-1.0.0.pkg":
+			_, _ = w.Write([]byte("package"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	oldBaseURL := githubAPIBaseURL
+	githubAPIBaseURL = server.URL
+	defer func() { githubAPIBaseURL = oldBaseURL }()
+
+	artifact := filepath.Join(tempDir, "Tool.app")
+	calls := filepath.Join(tempDir, "calls")
+	t.Setenv("FAKE_SUDO_CALLS", calls)
+	t.Setenv("FAKE_ARTIFACT", artifact)
+	fakeCommands(t, map[string]string{
+		"sudo": `echo "$@" >> "$FAKE_SUDO_CALLS"; mkdir -p "$FAKE_ARTIFACT"`,
+	})
+	choices := filepath.Join(tempDir, "choices.xml")
+	if err := os.WriteFile(choices, []byte("<array/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	step := map[string]string{"github_release": "vendor/tool", "asset": `Tool-.*\.pkg`, "choices": choices}
+	if err := installer.Install(context.Background(), []map[string]string{step}, artifact); err != nil {
+		t.Fatalf("Install with a pkg asset should not error: %v", err)
+	}
+	called, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !contains(string(called), "-applyChoiceChangesXML "+choices) {
+		t.Errorf("Expected the step's choices to be applied, got %q", called)
+	}
+
+	// 'inner' can't apply to a package
+	step = map[string]string{"github_release": "vendor/tool", "asset": `Tool-.*\.pkg`, "inner": "Tool.dmg"}
+	if err := installer.Install(context.Background(), []map[string]string{step}, artifact); err == nil || !contains(err.Error(), "'inner'") {
+		t.Errorf("Expected 'inner' with a pkg asset to error, got %v", err)
+	}
+}
+
+func TestFetchGitHubReleaseEscapesTag(t *testing.T) {
+	var requested string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.EscapedPath()
+		_, _ = w.Write([]byte(`{"tag_name": "release/2.0", "assets": []}`))
+	}))
+	defer server.Close()
+
+	oldBaseURL := githubAPIBaseURL
+	githubAPIBaseURL = server.URL
+	defer func() { githubAPIBaseURL = oldBaseURL }()
+
+	if _, err := New(t.TempDir()).fetchGitHubRelease(context.Background(), "vendor/tool", "release/2.0"); err != nil {
+		t.Fatalf("Fetching a release should not error: %v", err)
+	}
+	if requested != "/repos/vendor/tool/releases/tags/release%2F2.0" {
+		t.Errorf("Tag should be escaped in the API path, got %s", requested)
+	}
+}
+
+func TestInstallGitHubReleaseValidation(t *testing.T) {
+	installer := New(t.TempDir())
+
+	tests := []struct {
+		name string
+		step map[string]string
+	}{
+		{"missing asset", map[string]string{"github_release": "vendor/tool"}},
+		{"invalid repository", map[string]string{"github_release": "not a repo", "asset": "tool"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := installer.Install(context.Background(), []map[string]string{test.step}, "/tmp/tool")
+			if err == nil {
+				t.Fatal("Expected error")
+			}
+			if !contains(err.Error(), "github release installation failed") {
+				t.Errorf("Expected github release error, got: %v", err)
+			}
+		})
+	}
+}
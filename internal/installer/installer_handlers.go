@@ -0,0 +1,178 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// launchServicesDomain holds the user's default handler choices
+const launchServicesDomain = "com.apple.LaunchServices/com.apple.launchservices.secure"
+
+// handlerTarget is a file extension, UTI or URL scheme whose default app a
+// 'handlers' step sets
+type handlerTarget struct {
+	kind  string
+	value string
+}
+
+func (t handlerTarget) String() string {
+	switch t.kind {
+	case "extension":
+		return "." + t.value
+	case "scheme":
+		return t.value + "://"
+	default:
+		return t.value
+	}
+}
+
+// handlerTargets returns the extensions, UTIs and URL schemes listed by a
+// 'handlers' step
+func handlerTargets(step map[string]string) ([]handlerTarget, error) {
+	var targets []handlerTarget
+	for _, param := range []struct{ key, kind string }{
+		{"extensions", "extension"},
+		{"utis", "uti"},
+		{"schemes", "scheme"},
+	} {
+		value, ok := step[param.key]
+		if !ok {
+			continue
+		}
+		for _, item := range stepValueList(value) {
+			item = strings.TrimSpace(item)
+			switch param.kind {
+			case "extension":
+				item = strings.TrimPrefix(item, ".")
+			case "scheme":
+				item = strings.ToLower(strings.TrimSuffix(item, "://"))
+			}
+			if item == "" {
+				return nil, fmt.Errorf("'%s' contains an empty entry", param.key)
+			}
+			targets = append(targets, handlerTarget{kind: param.kind, value: item})
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("'handlers' requires 'extensions', 'utis' or 'schemes'")
+	}
+	return targets, nil
+}
+
+// configureHandlers makes an app, given by bundle ID, the default handler of
+// file extensions, UTIs and URL schemes using duti. Each handler is read back
+// after it is set, since Launch Services can silently refuse a change.
+func (i *Installer) configureHandlers(ctx context.Context, bundleID string, step map[string]string) error {
+	bundleID = strings.TrimSpace(bundleID)
+	if bundleID == "" {
+		return fmt.Errorf("'handlers' requires a bundle ID")
+	}
+	targets, err := handlerTargets(step)
+	if err != nil {
+		return err
+	}
+
+	role := "all"
+	if value, hasRole := step["role"]; hasRole {
+		role = strings.ToLower(strings.TrimSpace(value))
+		switch role {
+		case "all", "viewer", "editor", "shell":
+		default:
+			return fmt.Errorf("invalid role '%s' (use all, viewer, editor or shell)", value)
+		}
+	}
+
+	if _, err := exec.LookPath("duti"); err != nil {
+		return fmt.Errorf("'handlers' requires duti; install it with 'brew install duti'")
+	}
+
+	for _, target := range targets {
+		current, err := currentHandler(ctx, target)
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(current, bundleID) {
+			i.printf("%s is already handled by %s\n", target, bundleID)
+			continue
+		}
+
+		args := []string{"-s", bundleID, target.value}
+		if target.kind != "scheme" {
+			args = append(args, role)
+		}
+		if err := i.runCommand(ctx, "duti", args...); err != nil {
+			return fmt.Errorf("failed to set handler for %s: %w", target, err)
+		}
+
+		updated, err := currentHandler(ctx, target)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(updated, bundleID) {
+			return fmt.Errorf("handler for %s is still %s after setting it to %s", target, formatHandler(updated), bundleID)
+		}
+		i.printf("Changed handler for %s from %s to %s\n", target, formatHandler(current), bundleID)
+	}
+	return nil
+}
+
+// currentHandler returns the bundle ID of the default app for target, or ""
+// if there is none
+func currentHandler(ctx context.Context, target handlerTarget) (string, error) {
+	if target.kind == "scheme" {
+		return currentSchemeHandler(ctx, target.value)
+	}
+
+	// duti -x prints the app's name, path and bundle ID on separate lines;
+	// duti -d prints only the bundle ID. Both fail if there is no handler.
+	args := []string{"-d", target.value}
+	if target.kind == "extension" {
+		args = []string{"-x", target.value}
+	}
+	output, err := exec.CommandContext(ctx, "duti", args...).Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", nil
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1]), nil
+}
+
+// currentSchemeHandler returns the bundle ID the user chose to open URLs with
+// the given scheme, from the Launch Services preferences
+func currentSchemeHandler(ctx context.Context, scheme string) (string, error) {
+	output, err := exec.CommandContext(ctx, "defaults", "export", launchServicesDomain, "-").Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		// No handlers have been chosen yet
+		return "", nil
+	}
+
+	root, err := decodePlist(output)
+	if err != nil {
+		return "", fmt.Errorf("failed to read Launch Services handlers: %w", err)
+	}
+	dict, _ := root.(map[string]any)
+	handlers, _ := dict["LSHandlers"].([]any)
+	for _, entry := range handlers {
+		handler, _ := entry.(map[string]any)
+		if urlScheme, _ := handler["LSHandlerURLScheme"].(string); strings.EqualFold(urlScheme, scheme) {
+			bundleID, _ := handler["LSHandlerRoleAll"].(string)
+			return bundleID, nil
+		}
+	}
+	return "", nil
+}
+
+func formatHandler(bundleID string) string {
+	if bundleID == "" {
+		return "(none)"
+	}
+	return bundleID
+}
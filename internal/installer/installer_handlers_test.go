@@ -0,0 +1,125 @@
+package installer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cdzombak/mac-install/internal/config"
+)
+
+func TestHandlerTargets(t *testing.T) {
+	targets, err := handlerTargets(map[string]string{
+		"extensions": config.StepListMarker + "- .md\n- txt\n",
+		"utis":       "public.plain-text",
+		"schemes":    config.StepListMarker + "- HTTPS://\n",
+	})
+	if err != nil {
+		t.Fatalf("Valid step should not error: %v", err)
+	}
+	expected := []handlerTarget{
+		{"extension", "md"},
+		{"extension", "txt"},
+		{"uti", "public.plain-text"},
+		{"scheme", "https"},
+	}
+	if !reflect.DeepEqual(targets, expected) {
+		t.Errorf("Expected %v, got %v", expected, targets)
+	}
+
+	for _, step := range []map[string]string{{}, {"extensions": config.StepListMarker + "- .\n"}} {
+		if _, err := handlerTargets(step); err == nil {
+			t.Errorf("Step %v should error", step)
+		}
+	}
+}
+
+func TestConfigureHandlers(t *testing.T) {
+	handlers := t.TempDir()
+	calls := filepath.Join(t.TempDir(), "calls")
+	t.Setenv("FAKE_HANDLERS", handlers)
+	t.Setenv("FAKE_DUTI_CALLS", calls)
+
+	// Handlers are stored as one file per target; setting one whose name
+	// starts with "locked" has no effect, like a change Launch Services refuses
+	fakeCommands(t, map[string]string{
+		"duti": `
+case "$1" in
+-s)
+	echo "$@" >> "$FAKE_DUTI_CALLS"
+	case "$3" in locked*) exit 0 ;; esac
+	echo "$2" > "$FAKE_HANDLERS/$3"
+	;;
+-x)
+	test -f "$FAKE_HANDLERS/$2" || exit 1
+	echo "Some App"; echo "/Applications/Some App.app"; cat "$FAKE_HANDLERS/$2"
+	;;
+-d)
+	test -f "$FAKE_HANDLERS/$2" || exit 1
+	cat "$FAKE_HANDLERS/$2"
+	;;
+esac
+`,
+		"defaults": `
+echo '<plist version="1.0"><dict><key>LSHandlers</key><array>'
+for scheme in "$FAKE_HANDLERS"/*; do
+	echo "<dict><key>LSHandlerURLScheme</key><string>$(basename "$scheme")</string><key>LSHandlerRoleAll</key><string>$(cat "$scheme")</string></dict>"
+done
+echo '</array></dict></plist>'
+`,
+	})
+	if err := os.WriteFile(filepath.Join(handlers, "md"), []byte("com.example.editor\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(handlers, "http"), []byte("com.apple.safari\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var output bytes.Buffer
+	installer := New(t.TempDir()).WithOutput(&output)
+
+	err := installer.Configure(context.Background(), []map[string]string{
+		{"handlers": "com.example.editor", "extensions": config.StepListMarker + "- md\n- txt\n", "utis": "public.json", "role": "editor"},
+		{"handlers": "com.example.browser", "schemes": config.StepListMarker + "- http\n"},
+	})
+	if err != nil {
+		t.Fatalf("Configure should succeed: %v", err)
+	}
+
+	called, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedCalls := "-s com.example.editor txt editor\n-s com.example.editor public.json editor\n-s com.example.browser http\n"
+	if string(called) != expectedCalls {
+		t.Errorf("Only differing handlers should be set; expected %q, got %q", expectedCalls, called)
+	}
+	for _, expected := range []string{
+		".md is already handled by com.example.editor",
+		"Changed handler for .txt from (none) to com.example.editor",
+		"Changed handler for public.json from (none) to com.example.editor",
+		"Changed handler for http:// from com.apple.safari to com.example.browser",
+	} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("Output should contain %q, got:\n%s", expected, output.String())
+		}
+	}
+
+	err = installer.Configure(context.Background(), []map[string]string{
+		{"handlers": "com.example.editor", "utis": "locked.type"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "still (none)") {
+		t.Errorf("A handler that doesn't change should error, got %v", err)
+	}
+
+	err = installer.Configure(context.Background(), []map[string]string{
+		{"handlers": "com.example.editor", "extensions": "md", "role": "owner"},
+	})
+	if err == nil {
+		t.Error("Invalid role should error")
+	}
+}
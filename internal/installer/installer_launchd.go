@@ -0,0 +1,190 @@
+package installer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// calendarKeys maps the accepted keys of a 'calendar' entry to launchd's
+var calendarKeys = map[string]string{
+	"minute":  "Minute",
+	"hour":    "Hour",
+	"day":     "Day",
+	"weekday": "Weekday",
+	"month":   "Month",
+}
+
+// launchAgentPlist builds the property list of a LaunchAgent from a 'launchd'
+// configure step
+func launchAgentPlist(label string, step map[string]string) (map[string]any, error) {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return nil, fmt.Errorf("'launchd' requires a label")
+	}
+	plist := map[string]any{"Label": label}
+
+	program, hasProgram := step["program"]
+	if !hasProgram {
+		return nil, fmt.Errorf("'launchd' requires 'program'")
+	}
+	// A list is the program's arguments; a string is a shell command
+	args, isList := stepSequence(program)
+	if !isList {
+		args = []string{"/bin/sh", "-c", program}
+	}
+	plist["ProgramArguments"] = stringsToAny(args)
+
+	if interval, ok := step["interval"]; ok {
+		seconds, err := strconv.ParseInt(strings.TrimSpace(interval), 10, 64)
+		if err != nil || seconds <= 0 {
+			return nil, fmt.Errorf("invalid interval '%s': must be a positive number of seconds", interval)
+		}
+		plist["StartInterval"] = seconds
+	}
+
+	if calendar, ok := step["calendar"]; ok {
+		intervals, err := parseCalendarIntervals(calendar)
+		if err != nil {
+			return nil, err
+		}
+		plist["StartCalendarInterval"] = intervals
+	}
+
+	for param, key := range map[string]string{"keep_alive": "KeepAlive", "run_at_load": "RunAtLoad"} {
+		if value, ok := step[param]; ok {
+			enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s '%s': must be true or false", param, value)
+			}
+			plist[key] = enabled
+		}
+	}
+
+	if env, ok := step["env"]; ok {
+		var variables map[string]string
+		if err := yaml.Unmarshal([]byte(env), &variables); err != nil {
+			return nil, fmt.Errorf("'env' must be a mapping of variable names to values: %w", err)
+		}
+		environment := make(map[string]any, len(variables))
+		for name, value := range variables {
+			environment[name] = value
+		}
+		plist["EnvironmentVariables"] = environment
+	}
+
+	if logPath, ok := step["log"]; ok {
+		plist["StandardOutPath"] = logPath
+		plist["StandardErrorPath"] = logPath
+	}
+
+	return plist, nil
+}
+
+// parseCalendarIntervals parses a 'calendar' mapping, or list of mappings, into
+// StartCalendarInterval entries
+func parseCalendarIntervals(value string) (any, error) {
+	var entries []map[string]int64
+	if err := yaml.Unmarshal([]byte(value), &entries); err != nil {
+		var entry map[string]int64
+		if err := yaml.Unmarshal([]byte(value), &entry); err != nil {
+			return nil, fmt.Errorf("'calendar' must be a mapping, or list of mappings, of minute/hour/day/weekday/month to numbers")
+		}
+		entries = []map[string]int64{entry}
+	}
+
+	intervals := make([]any, 0, len(entries))
+	for _, entry := range entries {
+		interval := make(map[string]any, len(entry))
+		for key, value := range entry {
+			launchdKey, ok := calendarKeys[strings.ToLower(key)]
+			if !ok {
+				return nil, fmt.Errorf("unknown calendar key '%s' (use minute, hour, day, weekday or month)", key)
+			}
+			interval[launchdKey] = value
+		}
+		intervals = append(intervals, interval)
+	}
+
+	if len(intervals) == 1 {
+		return intervals[0], nil
+	}
+	return intervals, nil
+}
+
+func stringsToAny(values []string) []any {
+	items := make([]any, len(values))
+	for idx, value := range values {
+		items[idx] = value
+	}
+	return items
+}
+
+// encodePlist encodes a value as a complete XML property list document,
+// formatted the way Apple's tools write them
+func encodePlist(value any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	buf.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	buf.WriteString(`<plist version="1.0">` + "\n")
+	if err := writePlistValue(&buf, value, 0); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n</plist>\n")
+	return buf.Bytes(), nil
+}
+
+// configureLaunchAgent writes a LaunchAgent plist to ~/Library/LaunchAgents and
+// reloads the agent if the plist changed, or loads it if it isn't loaded
+func (i *Installer) configureLaunchAgent(ctx context.Context, label string, step map[string]string) error {
+	label = strings.TrimSpace(label)
+	plist, err := launchAgentPlist(label, step)
+	if err != nil {
+		return err
+	}
+
+	contents, err := encodePlist(plist)
+	if err != nil {
+		return err
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(homeDir, "Library", "LaunchAgents", label+".plist")
+	domain := fmt.Sprintf("gui/%d", os.Getuid())
+	target := domain + "/" + label
+
+	if fileHasContents(path, contents) {
+		// launchctl print fails if the agent isn't loaded
+		if err := exec.CommandContext(ctx, "launchctl", "print", target).Run(); err == nil {
+			i.printf("LaunchAgent %s is up to date\n", label)
+			return nil
+		}
+	} else {
+		if _, err := os.Stat(path); err == nil {
+			i.showDiff(ctx, path, contents)
+		}
+		if err := writeFileAtomically(path, contents, 0644); err != nil {
+			return fmt.Errorf("failed to write '%s': %w", path, err)
+		}
+		i.printf("Wrote %s\n", path)
+
+		// bootout fails if the agent isn't loaded, which is fine
+		_ = i.runCommand(ctx, "launchctl", "bootout", target)
+	}
+
+	if err := i.runCommand(ctx, "launchctl", "bootstrap", domain, path); err != nil {
+		return fmt.Errorf("failed to load LaunchAgent %s: %w", label, err)
+	}
+	i.printf("Loaded LaunchAgent %s\n", label)
+	return nil
+}
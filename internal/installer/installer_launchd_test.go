@@ -0,0 +1,168 @@
+package installer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cdzombak/mac-install/internal/config"
+)
+
+func TestLaunchAgentPlist(t *testing.T) {
+	plist, err := launchAgentPlist("com.example.backup", map[string]string{
+		"program":     config.StepListMarker + "- /usr/local/bin/backup\n- --quiet\n",
+		"interval":    "3600",
+		"calendar":    "hour: 3\nminute: 30\n",
+		"keep_alive":  "false",
+		"run_at_load": "true",
+		"env":         "PATH: /usr/bin:/bin\n",
+		"log":         "/tmp/backup.log",
+	})
+	if err != nil {
+		t.Fatalf("Valid step should not error: %v", err)
+	}
+
+	expected := map[string]any{
+		"Label":                 "com.example.backup",
+		"ProgramArguments":      []any{"/usr/local/bin/backup", "--quiet"},
+		"StartInterval":         int64(3600),
+		"StartCalendarInterval": map[string]any{"Hour": int64(3), "Minute": int64(30)},
+		"KeepAlive":             false,
+		"RunAtLoad":             true,
+		"EnvironmentVariables":  map[string]any{"PATH": "/usr/bin:/bin"},
+		"StandardOutPath":       "/tmp/backup.log",
+		"StandardErrorPath":     "/tmp/backup.log",
+	}
+	if !reflect.DeepEqual(plist, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, plist)
+	}
+
+	plist, err = launchAgentPlist("com.example.sync", map[string]string{
+		"program":  "sync-things >> /tmp/sync.log",
+		"calendar": "- weekday: 1\n  hour: 9\n- weekday: 5\n  hour: 17\n",
+	})
+	if err != nil {
+		t.Fatalf("Valid step should not error: %v", err)
+	}
+	if !reflect.DeepEqual(plist["ProgramArguments"], []any{"/bin/sh", "-c", "sync-things >> /tmp/sync.log"}) {
+		t.Errorf("A program string should run as a shell command, got %v", plist["ProgramArguments"])
+	}
+	if intervals, ok := plist["StartCalendarInterval"].([]any); !ok || len(intervals) != 2 {
+		t.Errorf("A calendar list should give several intervals, got %v", plist["StartCalendarInterval"])
+	}
+
+	for _, step := range []map[string]string{
+		{},
+		{"program": "x", "interval": "0"},
+		{"program": "x", "calendar": "hour: three\n"},
+		{"program": "x", "calendar": "second: 1\n"},
+		{"program": "x", "keep_alive": "sometimes"},
+		{"program": "x", "env": "- A=1\n"},
+	} {
+		if _, err := launchAgentPlist("com.example.bad", step); err == nil {
+			t.Errorf("Step %v should error", step)
+		}
+	}
+	if _, err := launchAgentPlist(" ", map[string]string{"program": "x"}); err == nil {
+		t.Error("Step without a label should error")
+	}
+}
+
+func TestEncodePlist(t *testing.T) {
+	contents, err := encodePlist(map[string]any{"Label": "x", "ProgramArguments": []any{"a", "b"}, "RunAtLoad": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>x</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>a</string>
+		<string>b</string>
+	</array>
+	<key>RunAtLoad</key>
+	<true/>
+</dict>
+</plist>
+`
+	if string(contents) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, contents)
+	}
+}
+
+func TestConfigureLaunchAgent(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	calls := filepath.Join(t.TempDir(), "calls")
+	loaded := filepath.Join(t.TempDir(), "loaded")
+	t.Setenv("FAKE_LAUNCHCTL_CALLS", calls)
+	t.Setenv("FAKE_LAUNCHCTL_LOADED", loaded)
+
+	fakeCommands(t, map[string]string{
+		"launchctl": `
+echo "$1" >> "$FAKE_LAUNCHCTL_CALLS"
+case "$1" in
+print) test -f "$FAKE_LAUNCHCTL_LOADED" ;;
+bootstrap) touch "$FAKE_LAUNCHCTL_LOADED" ;;
+bootout) test -f "$FAKE_LAUNCHCTL_LOADED" && rm "$FAKE_LAUNCHCTL_LOADED" ;;
+esac
+`,
+	})
+
+	readCalls := func() string {
+		contents, _ := os.ReadFile(calls)
+		_ = os.Remove(calls)
+		return strings.ReplaceAll(strings.TrimSpace(string(contents)), "\n", " ")
+	}
+
+	var output bytes.Buffer
+	installer := New(t.TempDir()).WithOutput(&output)
+	step := map[string]string{"launchd": "com.example.agent", "program": config.StepListMarker + "- /bin/true\n", "run_at_load": "true"}
+	plistPath := filepath.Join(home, "Library", "LaunchAgents", "com.example.agent.plist")
+
+	if err := installer.Configure(context.Background(), []map[string]string{step}); err != nil {
+		t.Fatalf("Configure should succeed: %v", err)
+	}
+	if _, err := os.Stat(plistPath); err != nil {
+		t.Fatal("LaunchAgent plist should be written")
+	}
+	if got := readCalls(); got != "bootout bootstrap" {
+		t.Errorf("New agent should be loaded, launchctl calls: %s", got)
+	}
+
+	if err := installer.Configure(context.Background(), []map[string]string{step}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readCalls(); got != "print" {
+		t.Errorf("Unchanged, loaded agent should be left alone, launchctl calls: %s", got)
+	}
+
+	_ = os.Remove(loaded)
+	if err := installer.Configure(context.Background(), []map[string]string{step}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readCalls(); got != "print bootstrap" {
+		t.Errorf("Unchanged agent that isn't loaded should be loaded, launchctl calls: %s", got)
+	}
+
+	output.Reset()
+	step["program"] = config.StepListMarker + "- /bin/echo\n"
+	if err := installer.Configure(context.Background(), []map[string]string{step}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readCalls(); got != "bootout bootstrap" {
+		t.Errorf("Changed agent should be reloaded, launchctl calls: %s", got)
+	}
+	if !strings.Contains(output.String(), "+\t\t<string>/bin/echo</string>") {
+		t.Errorf("Changes to the plist should be shown, output: %s", output.String())
+	}
+}
@@ -0,0 +1,113 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// applicationDirs returns the directories an app given by name is looked up in,
+// in order
+var applicationDirs = func() []string {
+	return []string{"/Applications", "/System/Applications", "/System/Applications/Utilities", homePath("Applications")}
+}
+
+// loginItemsScript prints the path of each login item on its own line
+const loginItemsScript = `tell application "System Events"
+	set output to ""
+	repeat with itemPath in (get path of every login item)
+		set output to output & itemPath & linefeed
+	end repeat
+	return output
+end tell`
+
+// resolveApp returns the path of an installed app given by path, or by name,
+// with or without its .app extension, in one of the applicationDirs
+func resolveApp(app string) (string, error) {
+	app = strings.TrimSpace(app)
+	if app == "" {
+		return "", fmt.Errorf("app name is empty")
+	}
+	if filepath.IsAbs(app) {
+		path := filepath.Clean(app)
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("app '%s' not found: %w", path, err)
+		}
+		return path, nil
+	}
+
+	if !strings.HasSuffix(app, ".app") {
+		app += ".app"
+	}
+	dirs := applicationDirs()
+	for _, dir := range dirs {
+		path := filepath.Join(dir, app)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("app '%s' not found in %s", app, strings.Join(dirs, ", "))
+}
+
+// configureLoginItem adds an app to the current user's login items unless it
+// is already one. With 'hidden: true' the app is hidden when it launches.
+func (i *Installer) configureLoginItem(ctx context.Context, app string, step map[string]string) error {
+	app, err := resolveApp(app)
+	if err != nil {
+		return err
+	}
+
+	hidden := false
+	if value, hasHidden := step["hidden"]; hasHidden {
+		if hidden, err = strconv.ParseBool(strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("invalid hidden '%s': must be true or false", value)
+		}
+	}
+
+	items, err := loginItems(ctx)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if filepath.Clean(item) == app {
+			i.printf("%s is already a login item\n", app)
+			return nil
+		}
+	}
+
+	script := fmt.Sprintf(`tell application "System Events" to make login item at end with properties {path:%s, hidden:%t}`, appleScriptString(app), hidden)
+	if err := i.runCommand(ctx, "osascript", "-e", script); err != nil {
+		return fmt.Errorf("failed to add login item %s: %w", app, err)
+	}
+	i.printf("Added %s to login items\n", app)
+	return nil
+}
+
+// loginItems returns the paths of the current user's login items
+func loginItems(ctx context.Context) ([]string, error) {
+	output, err := exec.CommandContext(ctx, "osascript", "-e", loginItemsScript).Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("failed to list login items: %w", err)
+	}
+
+	var items []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			items = append(items, line)
+		}
+	}
+	return items, nil
+}
+
+// appleScriptString quotes s as an AppleScript string literal
+func appleScriptString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
@@ -0,0 +1,99 @@
+package installer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigureLoginItem(t *testing.T) {
+	apps := t.TempDir()
+	oldDirs := applicationDirs
+	applicationDirs = func() []string { return []string{apps} }
+	t.Cleanup(func() { applicationDirs = oldDirs })
+	for _, app := range []string{"Existing.app", "New App.app"} {
+		if err := os.Mkdir(filepath.Join(apps, app), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	added := filepath.Join(t.TempDir(), "added")
+	t.Setenv("FAKE_OSASCRIPT_ADDED", added)
+	t.Setenv("FAKE_APPS", apps)
+	fakeCommands(t, map[string]string{
+		"osascript": `
+case "$2" in
+*"make login item"*) echo "$2" >> "$FAKE_OSASCRIPT_ADDED" ;;
+*) echo "$FAKE_APPS/Existing.app" ;;
+esac
+`,
+	})
+
+	var output bytes.Buffer
+	installer := New(t.TempDir()).WithOutput(&output)
+
+	err := installer.Configure(context.Background(), []map[string]string{
+		{"login_item": "Existing"},
+		{"login_item": filepath.Join(apps, "New App.app"), "hidden": "true"},
+	})
+	if err != nil {
+		t.Fatalf("Configure should succeed: %v", err)
+	}
+
+	script, err := os.ReadFile(added)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `tell application "System Events" to make login item at end with properties {path:"` + filepath.Join(apps, "New App.app") + `", hidden:true}` + "\n"
+	if string(script) != expected {
+		t.Errorf("Only the new login item should be added; expected %q, got %q", expected, script)
+	}
+	if !strings.Contains(output.String(), "Existing.app is already a login item") {
+		t.Errorf("Existing login item should be reported, output: %s", output.String())
+	}
+
+	for _, step := range []map[string]string{
+		{"login_item": "Missing"},
+		{"login_item": "Existing", "hidden": "sometimes"},
+	} {
+		if err := installer.Configure(context.Background(), []map[string]string{step}); err == nil {
+			t.Errorf("Step %v should error", step)
+		}
+	}
+}
+
+func TestResolveApp(t *testing.T) {
+	apps, systemApps := t.TempDir(), t.TempDir()
+	oldDirs := applicationDirs
+	applicationDirs = func() []string { return []string{apps, systemApps} }
+	t.Cleanup(func() { applicationDirs = oldDirs })
+	for _, path := range []string{filepath.Join(apps, "Safari.app"), filepath.Join(systemApps, "Safari.app"), filepath.Join(systemApps, "Terminal.app")} {
+		if err := os.Mkdir(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		app      string
+		expected string
+	}{
+		{"Safari", filepath.Join(apps, "Safari.app")},
+		{"Terminal.app", filepath.Join(systemApps, "Terminal.app")},
+		{filepath.Join(systemApps, "Safari.app"), filepath.Join(systemApps, "Safari.app")},
+	}
+	for _, test := range tests {
+		path, err := resolveApp(test.app)
+		if err != nil || path != test.expected {
+			t.Errorf("resolveApp(%q): expected %s, got %s (%v)", test.app, test.expected, path, err)
+		}
+	}
+
+	for _, app := range []string{"", "Missing", filepath.Join(apps, "Terminal.app")} {
+		if _, err := resolveApp(app); err == nil {
+			t.Errorf("resolveApp(%q) should error", app)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// installPkg installs a flat or distribution installer package using the system
+// installer. The package source may be a URL, a local path (relative paths are
+// resolved against the working directory), or, when an archive URL is given, the
+// name of a package found inside the extracted (possibly nested) archive.
+func (i *Installer) installPkg(ctx context.Context, pkgSource, archiveURL string, hasArchive bool, innerPaths []string, choicesPath, artifactPath string) error {
+	var pkgPath string
+
+	if hasArchive {
+		extractDir, cleanup, err := i.prepareArchive(ctx, archiveURL, innerPaths)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		pkgPath, err = i.findFileInDirectory(extractDir, pkgSource)
+		if err != nil {
+			return fmt.Errorf("failed to find package '%s' in archive: %w", pkgSource, err)
+		}
+	} else if IsRemoteURL(pkgSource) {
+		tempDir, err := os.MkdirTemp("", "mac-install-pkg-*")
+		if err != nil {
+			return fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		defer func() {
+			if err := os.RemoveAll(tempDir); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to remove temp directory: %v\n", err)
+			}
+		}()
+
+		pkgPath, err = i.downloadFile(ctx, pkgSource, filepath.Join(tempDir, "package.pkg"))
+		if err != nil {
+			return fmt.Errorf("failed to download package: %w", err)
+		}
+	} else {
+		pkgPath = i.resolveLocalPath(pkgSource)
+		if _, err := os.Stat(pkgPath); err != nil {
+			return fmt.Errorf("package not found: %w", err)
+		}
+	}
+
+	if choicesPath != "" {
+		choicesPath = i.resolveLocalPath(choicesPath)
+		if _, err := os.Stat(choicesPath); err != nil {
+			return fmt.Errorf("choice changes file not found: %w", err)
+		}
+	}
+
+	if err := i.runCommand(ctx, "sudo", pkgInstallerArgs(pkgPath, choicesPath)...); err != nil {
+		return fmt.Errorf("installer failed for '%s': %w", pkgPath, err)
+	}
+
+	if !i.ArtifactExists(ctx, artifactPath) {
+		return &ArtifactMissingError{Artifact: artifactPath}
+	}
+
+	return nil
+}
+
+// pkgInstallerArgs builds the arguments for running installer(8) via sudo.
+func pkgInstallerArgs(pkgPath, choicesPath string) []string {
+	args := []string{"installer", "-pkg", pkgPath, "-target", "/"}
+	if choicesPath != "" {
+		args = append(args, "-applyChoiceChangesXML", choicesPath)
+	}
+	return args
+}
+
+// resolveLocalPath resolves a relative path against the working directory
+func (i *Installer) resolveLocalPath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(i.workDir, path)
+}
+
+// IsRemoteURL reports whether value is an http or https URL rather than a local path
+func IsRemoteURL(value string) bool {
+	lower := strings.ToLower(value)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
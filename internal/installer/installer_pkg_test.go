@@ -0,0 +1,86 @@
+package installer
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPkgInstallerArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		pkgPath     string
+		choicesPath string
+		expected    []string
+	}{
+		{
+			name:     "without choices",
+			pkgPath:  "/tmp/Tool.pkg",
+			expected: []string{"installer", "-pkg", "/tmp/Tool.pkg", "-target", "/"},
+		},
+		{
+			name:        "with choices",
+			pkgPath:     "/tmp/Tool.pkg",
+			choicesPath: "/tmp/choices.xml",
+			expected:    []string{"installer", "-pkg", "/tmp/Tool.pkg", "-target", "/", "-applyChoiceChangesXML", "/tmp/choices.xml"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := pkgInstallerArgs(test.pkgPath, test.choicesPath)
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestResolveLocalPath(t *testing.T) {
+	workDir := t.TempDir()
+	installer := New(workDir)
+
+	if result := installer.resolveLocalPath("/abs/Tool.pkg"); result != "/abs/Tool.pkg" {
+		t.Errorf("Absolute path should be unchanged, got %s", result)
+	}
+
+	expected := filepath.Join(workDir, "pkgs", "Tool.pkg")
+	if result := installer.resolveLocalPath("pkgs/Tool.pkg"); result != expected {
+		t.Errorf("Expected %s, got %s", expected, result)
+	}
+}
+
+func TestIsRemoteURL(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected bool
+	}{
+		{"https://example.com/Tool.pkg", true},
+		{"HTTP://example.com/Tool.pkg", true},
+		{"/Users/me/Tool.pkg", false},
+		{"pkgs/Tool.pkg", false},
+	}
+
+	for _, test := range tests {
+		if result := IsRemoteURL(test.value); result != test.expected {
+			t.Errorf("IsRemoteURL(%q): expected %v, got %v", test.value, test.expected, result)
+		}
+	}
+}
+
+func TestInstallPkgMissingLocalPackage(t *testing.T) {
+	installer := New(t.TempDir())
+
+	installSteps := []map[string]string{
+		{"pkg": "missing/Tool.pkg"},
+	}
+
+	err := installer.Install(context.Background(), installSteps, "/Applications/Tool.app")
+	if err == nil {
+		t.Fatal("Expected error for missing package")
+	}
+	if !contains(err.Error(), "pkg installation failed") {
+		t.Errorf("Expected pkg installation error, got: %v", err)
+	}
+}
@@ -0,0 +1,318 @@
+package installer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// Prefetcher downloads URLs in the background into a temporary cache directory,
+// so that a later download of the same URL by the installer is served locally.
+type Prefetcher struct {
+	cacheDir string
+	ctx      context.Context
+	cancel   context.CancelFunc
+	workers  chan struct{}
+	wg       sync.WaitGroup
+	log      io.Writer
+
+	mu      sync.Mutex
+	entries map[string]*prefetchEntry
+	count   int
+	// maxBytes bounds the cache; downloads that would grow it past the bound
+	// are abandoned and left to the installer
+	maxBytes  int64
+	usedBytes int64
+}
+
+type prefetchEntry struct {
+	done     chan struct{}
+	started  bool
+	skipped  bool
+	path     string
+	header   http.Header
+	finalURL *url.URL
+	size     int64
+	err      error
+}
+
+// errCacheFull abandons a prefetch that would grow the cache past its bound
+var errCacheFull = errors.New("prefetch cache is full")
+
+// NewPrefetcher creates a prefetcher that downloads up to workers URLs at once,
+// keeping at most maxBytes in its cache, and stopping if ctx is cancelled.
+// Close must be called to stop downloads and remove the cache directory.
+func NewPrefetcher(ctx context.Context, workers int, maxBytes int64) (*Prefetcher, error) {
+	cacheDir, err := os.MkdirTemp("", "mac-install-prefetch-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create prefetch directory: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	return &Prefetcher{
+		cacheDir: cacheDir,
+		ctx:      ctx,
+		cancel:   cancel,
+		workers:  make(chan struct{}, workers),
+		entries:  make(map[string]*prefetchEntry),
+		maxBytes: maxBytes,
+	}, nil
+}
+
+// SetLog records each prefetch in w. It must be called before Prefetch.
+func (p *Prefetcher) SetLog(w io.Writer) {
+	p.log = w
+}
+
+func (p *Prefetcher) logf(format string, args ...any) {
+	if p.log != nil {
+		fmt.Fprintf(p.log, format, args...)
+	}
+}
+
+// Prefetch queues rawURL for background download. URLs already queued are ignored.
+func (p *Prefetcher) Prefetch(rawURL string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, exists := p.entries[rawURL]; exists {
+		return
+	}
+	p.count++
+	entry := &prefetchEntry{
+		done: make(chan struct{}),
+		path: filepath.Join(p.cacheDir, strconv.Itoa(p.count)),
+	}
+	p.entries[rawURL] = entry
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer close(entry.done)
+
+		select {
+		case p.workers <- struct{}{}:
+		case <-p.ctx.Done():
+			entry.err = p.ctx.Err()
+			return
+		}
+		defer func() { <-p.workers }()
+
+		p.mu.Lock()
+		if entry.skipped {
+			p.mu.Unlock()
+			return
+		}
+		entry.started = true
+		p.mu.Unlock()
+
+		cache := &cacheWriter{p: p}
+		entry.header, entry.finalURL, entry.err = p.download(rawURL, entry.path, cache)
+		entry.size = cache.size
+		if entry.err != nil {
+			_ = os.Remove(entry.path)
+			p.release(entry.size)
+			p.logf("prefetch: %s not prefetched: %v\n", rawURL, entry.err)
+			return
+		}
+		p.logf("prefetch: downloaded %s (%d bytes)\n", rawURL, entry.size)
+	}()
+}
+
+func (p *Prefetcher) download(rawURL, path string, cache *cacheWriter) (http.Header, *url.URL, error) {
+	req, err := http.NewRequestWithContext(p.ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("download failed with status: %s", resp.Status)
+	}
+	// Don't start a download whose declared size can't fit
+	if resp.ContentLength > 0 && !p.fits(resp.ContentLength) {
+		return nil, nil, errCacheFull
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	cache.w = out
+	if _, err := io.Copy(cache, resp.Body); err != nil {
+		_ = out.Close()
+		return nil, nil, err
+	}
+	if err := out.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	return resp.Header, resp.Request.URL, nil
+}
+
+// cacheWriter writes a download into the cache, claiming space for it as it goes
+type cacheWriter struct {
+	p    *Prefetcher
+	w    io.Writer
+	size int64
+}
+
+func (c *cacheWriter) Write(b []byte) (int, error) {
+	if err := c.p.reserve(int64(len(b))); err != nil {
+		return 0, err
+	}
+	c.size += int64(len(b))
+	return c.w.Write(b)
+}
+
+// fits reports whether n more bytes would currently fit in the cache
+func (p *Prefetcher) fits(n int64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.maxBytes <= 0 || p.usedBytes+n <= p.maxBytes
+}
+
+// reserve claims n bytes of the cache, failing if that would exceed its bound
+func (p *Prefetcher) reserve(n int64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.maxBytes > 0 && p.usedBytes+n > p.maxBytes {
+		return errCacheFull
+	}
+	p.usedBytes += n
+	return nil
+}
+
+// release returns n bytes to the cache once a download leaves it
+func (p *Prefetcher) release(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.usedBytes -= n
+}
+
+// take returns the completed prefetch for rawURL, removing it from the cache.
+// If the download is in progress, take waits for it until ctx is done; if it
+// hasn't started yet, it is skipped so the caller can download directly without
+// queueing.
+func (p *Prefetcher) take(ctx context.Context, rawURL string) (*prefetchEntry, bool) {
+	if p == nil {
+		return nil, false
+	}
+
+	p.mu.Lock()
+	entry, exists := p.entries[rawURL]
+	if !exists {
+		p.mu.Unlock()
+		return nil, false
+	}
+	delete(p.entries, rawURL)
+	if !entry.started {
+		entry.skipped = true
+		p.mu.Unlock()
+		return nil, false
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-entry.done:
+	case <-ctx.Done():
+		// A stalled prefetch mustn't hold up a step past its time limit
+		go p.discard(entry)
+		return nil, false
+	}
+	if entry.err != nil {
+		return nil, false
+	}
+	// The caller moves the file out of the cache
+	p.release(entry.size)
+	return entry, true
+}
+
+// discard removes an abandoned prefetch from the cache once it finishes
+func (p *Prefetcher) discard(entry *prefetchEntry) {
+	<-entry.done
+	if entry.err == nil {
+		_ = os.Remove(entry.path)
+		p.release(entry.size)
+	}
+}
+
+// Close cancels outstanding downloads and removes the cache directory
+func (p *Prefetcher) Close() {
+	p.cancel()
+	p.wg.Wait()
+	if err := os.RemoveAll(p.cacheDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to remove prefetch directory: %v\n", err)
+	}
+}
+
+// SetPrefetcher makes the installer serve downloads from p when possible
+func (i *Installer) SetPrefetcher(p *Prefetcher) {
+	i.prefetcher = p
+}
+
+// takePrefetched moves a prefetched download of rawURL into place, choosing the
+// final path from the prefetched response just as download would
+func (i *Installer) takePrefetched(ctx context.Context, rawURL, path string, nameFromResponse bool) (string, bool) {
+	entry, ok := i.prefetcher.take(ctx, rawURL)
+	if !ok {
+		return "", false
+	}
+
+	actualFilepath := path
+	if nameFromResponse {
+		actualFilepath = i.determineFilepath(path, &http.Response{
+			Header:  entry.header,
+			Request: &http.Request{URL: entry.finalURL},
+		})
+	}
+	if err := moveFile(entry.path, actualFilepath); err != nil {
+		return "", false
+	}
+	if i.log != nil {
+		fmt.Fprintf(i.log, "Using prefetched download of %s\n", rawURL)
+	}
+	return actualFilepath, true
+}
+
+// moveFile renames src to dest, falling back to copying across filesystems
+func moveFile(src, dest string) error {
+	if err := os.Rename(src, dest); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
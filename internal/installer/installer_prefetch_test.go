@@ -0,0 +1,200 @@
+package installer
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPrefetchServesDownload(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write([]byte("prefetched content"))
+	}))
+	defer server.Close()
+
+	prefetcher, err := NewPrefetcher(context.Background(), 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prefetcher.Close()
+
+	url := server.URL + "/tool"
+	prefetcher.Prefetch(url)
+	prefetcher.Prefetch(url) // duplicates are ignored
+
+	// Wait for the background download to finish
+	prefetcher.mu.Lock()
+	entry := prefetcher.entries[url]
+	prefetcher.mu.Unlock()
+	<-entry.done
+
+	tempDir := t.TempDir()
+	installer := New(tempDir)
+	installer.SetPrefetcher(prefetcher)
+
+	artifact := filepath.Join(tempDir, "bin", "tool")
+	if err := installer.Install(context.Background(), []map[string]string{{"dl": url}}, artifact); err != nil {
+		t.Fatalf("Install with prefetched dl should not error: %v", err)
+	}
+
+	content, err := os.ReadFile(artifact)
+	if err != nil || string(content) != "prefetched content" {
+		t.Errorf("Expected prefetched content, got %q (%v)", content, err)
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("Expected 1 request to the server, got %d", got)
+	}
+
+	// A prefetched download is consumed once; later downloads hit the network
+	if err := installer.Install(context.Background(), []map[string]string{{"dl": url}}, artifact); err != nil {
+		t.Fatalf("Second install should not error: %v", err)
+	}
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("Expected 2 requests to the server, got %d", got)
+	}
+}
+
+func TestPrefetchFailureFallsBack(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	prefetcher, err := NewPrefetcher(context.Background(), 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prefetcher.Close()
+
+	url := server.URL + "/tool"
+	prefetcher.Prefetch(url)
+
+	prefetcher.mu.Lock()
+	entry := prefetcher.entries[url]
+	prefetcher.mu.Unlock()
+	<-entry.done
+
+	tempDir := t.TempDir()
+	installer := New(tempDir)
+	installer.SetPrefetcher(prefetcher)
+
+	artifact := filepath.Join(tempDir, "tool")
+	if err := installer.Install(context.Background(), []map[string]string{{"dl": url}}, artifact); err != nil {
+		t.Fatalf("Install should fall back to a direct download: %v", err)
+	}
+	if _, err := os.Stat(artifact); err != nil {
+		t.Errorf("Downloaded file should exist: %v", err)
+	}
+}
+
+func TestPrefetchStallRespectsStepTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Stall until the client gives up
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	prefetcher, err := NewPrefetcher(context.Background(), 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prefetcher.Close()
+
+	url := server.URL + "/tool"
+	prefetcher.Prefetch(url)
+	for started := false; !started; {
+		prefetcher.mu.Lock()
+		started = prefetcher.entries[url].started
+		prefetcher.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	tempDir := t.TempDir()
+	installer := New(tempDir)
+	installer.SetPrefetcher(prefetcher)
+
+	start := time.Now()
+	err = installer.Install(context.Background(), []map[string]string{{"dl": url, "timeout": "200ms"}}, filepath.Join(tempDir, "tool"))
+	if err == nil {
+		t.Fatal("Expected the step to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Step should stop waiting for the prefetch at its timeout, took %v", elapsed)
+	}
+}
+
+func TestPrefetchCacheLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("0123456789"))
+	}))
+	defer server.Close()
+
+	// Room for one download but not two
+	prefetcher, err := NewPrefetcher(context.Background(), 1, 15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prefetcher.Close()
+	var log bytes.Buffer
+	prefetcher.SetLog(&log)
+
+	first, second := server.URL+"/first", server.URL+"/second"
+	for _, url := range []string{first, second} {
+		prefetcher.Prefetch(url)
+		prefetcher.mu.Lock()
+		entry := prefetcher.entries[url]
+		prefetcher.mu.Unlock()
+		<-entry.done
+	}
+
+	if _, ok := prefetcher.take(context.Background(), second); ok {
+		t.Error("A download past the cache limit should not be served")
+	}
+	if _, ok := prefetcher.take(context.Background(), first); !ok {
+		t.Error("A download within the cache limit should be served")
+	}
+	if prefetcher.usedBytes != 0 {
+		t.Errorf("Taken and abandoned downloads should free their space, %d bytes still used", prefetcher.usedBytes)
+	}
+
+	for _, expected := range []string{"prefetch: downloaded " + first, "prefetch: " + second + " not prefetched: prefetch cache is full"} {
+		if !strings.Contains(log.String(), expected) {
+			t.Errorf("Log should contain %q, got:\n%s", expected, log.String())
+		}
+	}
+}
+
+func TestPrefetcherCloseRemovesCache(t *testing.T) {
+	prefetcher, err := NewPrefetcher(context.Background(), 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := prefetcher.take(context.Background(), "https://example.com/not-queued"); ok {
+		t.Error("take should report false for URLs that were never queued")
+	}
+
+	prefetcher.Close()
+	if _, err := os.Stat(prefetcher.cacheDir); !os.IsNotExist(err) {
+		t.Error("Close should remove the cache directory")
+	}
+
+	var nilPrefetcher *Prefetcher
+	if _, ok := nilPrefetcher.take(context.Background(), "https://example.com/tool"); ok {
+		t.Error("take on a nil prefetcher should report false")
+	}
+}
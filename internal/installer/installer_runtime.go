@@ -0,0 +1,150 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cdzombak/mac-install/internal/config"
+	"github.com/cdzombak/mac-install/internal/toolchain"
+)
+
+// installRuntime installs a language runtime version, such as "python@3.12",
+// with mise or asdf. 'plugin' gives a plugin URL to add first, and 'global:
+// true' makes the version the user's default.
+func (i *Installer) installRuntime(ctx context.Context, runtime string, step map[string]string) error {
+	// The version may come from the environment, e.g. python@$ENV_PYTHON_VERSION
+	runtime, err := config.ExpandEnvVariables(runtime)
+	if err != nil {
+		return err
+	}
+	tool, version := toolchain.ParseRuntime(runtime)
+	if tool == "" {
+		return fmt.Errorf("'runtime' requires a tool, such as python@3.12")
+	}
+
+	global := false
+	if value, hasGlobal := step["global"]; hasGlobal {
+		if global, err = strconv.ParseBool(strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("invalid global '%s': must be true or false", value)
+		}
+	}
+
+	switch manager := runtimeManager(step); manager {
+	case "mise":
+		return i.installMiseRuntime(ctx, tool, version, step["plugin"], global)
+	case "asdf":
+		return i.installAsdfRuntime(ctx, tool, version, step["plugin"], global)
+	default:
+		return fmt.Errorf("unsupported runtime manager '%s' (use mise or asdf)", manager)
+	}
+}
+
+// runtimeManager returns the version manager a 'runtime' step uses: its
+// 'manager' parameter, or mise if it's installed and asdf otherwise. Software
+// without an artifact must name its manager, so the artifact the config derived
+// always belongs to the manager used here.
+func runtimeManager(step map[string]string) string {
+	if manager := strings.ToLower(strings.TrimSpace(step["manager"])); manager != "" {
+		return manager
+	}
+	if runtimeManagerBinary("mise") != "mise" {
+		return "mise"
+	}
+	return "asdf"
+}
+
+// runtimeManagerBinary returns the path of mise or asdf, like toolBinary. The
+// mise installer puts it in ~/.local/bin, and asdf before 0.16 was cloned into
+// ~/.asdf.
+func runtimeManagerBinary(manager string) string {
+	brewBin := filepath.Join(config.BrewPrefix(), "bin")
+	if manager == "mise" {
+		return toolBinary("mise", toolchain.LocalBinDir(), brewBin)
+	}
+	return toolBinary("asdf", brewBin, homePath(".asdf", "bin"))
+}
+
+func (i *Installer) installMiseRuntime(ctx context.Context, tool, version, plugin string, global bool) error {
+	mise := runtimeManagerBinary("mise")
+	if plugin != "" {
+		if err := i.runCommand(ctx, mise, "plugins", "install", "--yes", tool, plugin); err != nil {
+			return fmt.Errorf("failed to add mise plugin %s: %w", tool, err)
+		}
+	}
+
+	spec := tool + "@" + version
+	// 'mise use' installs the version as well
+	if global {
+		return i.runCommand(ctx, mise, "use", "--global", spec)
+	}
+	return i.runCommand(ctx, mise, "install", spec)
+}
+
+func (i *Installer) installAsdfRuntime(ctx context.Context, tool, version, plugin string, global bool) error {
+	asdf := runtimeManagerBinary("asdf")
+	plugins, err := exec.CommandContext(ctx, asdf, "plugin", "list").Output()
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if !containsLine(string(plugins), tool) {
+		args := []string{"plugin", "add", tool}
+		if plugin != "" {
+			args = append(args, plugin)
+		}
+		if err := i.runCommand(ctx, asdf, args...); err != nil {
+			return fmt.Errorf("failed to add asdf plugin %s: %w", tool, err)
+		}
+	}
+
+	// asdf needs "latest:3.12" to resolve a partial version
+	if toolchain.IsPartialVersion(version) {
+		version = "latest:" + version
+	}
+	if err := i.runCommand(ctx, asdf, "install", tool, version); err != nil {
+		return err
+	}
+	if !global {
+		return nil
+	}
+
+	// asdf 0.16 replaced 'asdf global' with 'asdf set -u'
+	if i.asdfBefore016(ctx, asdf) {
+		return i.runCommand(ctx, asdf, "global", tool, version)
+	}
+	return i.runCommand(ctx, asdf, "set", "-u", tool, version)
+}
+
+// asdfBefore016 reports whether asdf is older than 0.16, going by 'asdf
+// --version', which prints e.g. "v0.15.0-31e8c93" or "asdf version 0.16.7". An
+// unrecognized version is taken to be current.
+func (i *Installer) asdfBefore016(ctx context.Context, asdf string) bool {
+	output, err := i.commandOutput(ctx, asdf, "--version")
+	if err != nil {
+		return false
+	}
+	matches := asdfVersion.FindStringSubmatch(string(output))
+	if matches == nil {
+		return false
+	}
+	major, _ := strconv.Atoi(matches[1])
+	minor, _ := strconv.Atoi(matches[2])
+	return major == 0 && minor < 16
+}
+
+var asdfVersion = regexp.MustCompile(`v?([0-9]+)\.([0-9]+)\.[0-9]+`)
+
+// containsLine reports whether output has a line that is exactly s, ignoring
+// surrounding whitespace
+func containsLine(output, s string) bool {
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package installer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInstallRuntime(t *testing.T) {
+	calls := filepath.Join(t.TempDir(), "calls")
+	t.Setenv("FAKE_RUNTIME_CALLS", calls)
+
+	fakeCommands(t, map[string]string{
+		"mise": `echo "mise $*" >> "$FAKE_RUNTIME_CALLS"`,
+		// asdf 0.15 has no 'set' command, so 'global' is used instead
+		"asdf": `
+case "$1" in
+--version) echo "v0.15.0-31e8c93" && exit 0 ;;
+plugin) [ "$2" = "list" ] && echo python && exit 0 ;;
+set) exit 1 ;;
+esac
+echo "asdf $*" >> "$FAKE_RUNTIME_CALLS"
+`,
+	})
+
+	// Versions may come from the environment
+	t.Setenv("NODE_VERSION", "20")
+
+	installer := New(t.TempDir())
+	err := installer.Install(context.Background(), []map[string]string{
+		{"runtime": "node@$ENV_NODE_VERSION", "manager": "mise"},
+		{"runtime": "python@3.12.3", "manager": "mise", "global": "true"},
+		{"runtime": "python@3.12", "manager": "asdf", "global": "true"},
+		{"runtime": "elixir", "manager": "asdf", "plugin": "https://github.com/asdf-vm/asdf-elixir.git"},
+	}, "")
+	if err != nil {
+		t.Fatalf("Install should succeed: %v", err)
+	}
+
+	called, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "mise install node@20\n" +
+		"mise use --global python@3.12.3\n" +
+		"asdf install python latest:3.12\n" +
+		"asdf global python latest:3.12\n" +
+		"asdf plugin add elixir https://github.com/asdf-vm/asdf-elixir.git\n" +
+		"asdf install elixir latest\n"
+	if string(called) != expected {
+		t.Errorf("Expected calls %q, got %q", expected, called)
+	}
+
+	for _, step := range []map[string]string{
+		{"runtime": "@3.12", "manager": "mise"},
+		{"runtime": "python@3.12", "manager": "pyenv"},
+		{"runtime": "python@3.12", "manager": "mise", "global": "yes please"},
+	} {
+		if err := installer.Install(context.Background(), []map[string]string{step}, ""); err == nil {
+			t.Errorf("Step %v should error", step)
+		}
+	}
+}
+
+func TestInstallAsdfRuntimeGlobal(t *testing.T) {
+	calls := filepath.Join(t.TempDir(), "calls")
+	t.Setenv("FAKE_RUNTIME_CALLS", calls)
+
+	// A failure of 'asdf set' on asdf 0.16 is reported, not retried with the
+	// 'global' command it no longer has
+	fakeCommands(t, map[string]string{
+		"asdf": `
+case "$1" in
+--version) echo "asdf version 0.16.7" && exit 0 ;;
+plugin) echo python && exit 0 ;;
+set) echo "asdf $*" >> "$FAKE_RUNTIME_CALLS"; exit "$FAKE_SET_STATUS" ;;
+esac
+echo "asdf $*" >> "$FAKE_RUNTIME_CALLS"
+`,
+	})
+
+	step := map[string]string{"runtime": "python@3.12.3", "manager": "asdf", "global": "true"}
+	installer := New(t.TempDir())
+	t.Setenv("FAKE_SET_STATUS", "0")
+	if err := installer.Install(context.Background(), []map[string]string{step}, ""); err != nil {
+		t.Fatalf("Install should succeed: %v", err)
+	}
+	t.Setenv("FAKE_SET_STATUS", "1")
+	if err := installer.Install(context.Background(), []map[string]string{step}, ""); err == nil {
+		t.Error("A failing 'asdf set' should fail the step")
+	}
+
+	called, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "asdf install python 3.12.3\nasdf set -u python 3.12.3\n" +
+		"asdf install python 3.12.3\nasdf set -u python 3.12.3\n"
+	if string(called) != expected {
+		t.Errorf("Expected calls %q, got %q", expected, called)
+	}
+}
+
+func TestRuntimeManagerFallback(t *testing.T) {
+	calls := filepath.Join(t.TempDir(), "calls")
+	t.Setenv("FAKE_RUNTIME_CALLS", calls)
+
+	// mise's installer puts it in ~/.local/bin, which may not be on PATH yet
+	binDir := t.TempDir()
+	t.Setenv("XDG_BIN_HOME", binDir)
+	t.Setenv("PATH", "/usr/bin:/bin")
+	if err := os.WriteFile(filepath.Join(binDir, "mise"), []byte("#!/bin/sh\necho \"mise $*\" >> \"$FAKE_RUNTIME_CALLS\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if manager := runtimeManager(map[string]string{"runtime": "node"}); manager != "mise" {
+		t.Errorf("Expected mise outside PATH to be found, got %s", manager)
+	}
+	if manager := runtimeManager(map[string]string{"runtime": "node", "manager": "ASDF"}); manager != "asdf" {
+		t.Errorf("Expected the named manager, got %s", manager)
+	}
+
+	installer := New(t.TempDir())
+	if err := installer.Install(context.Background(), []map[string]string{{"runtime": "node@20"}}, ""); err != nil {
+		t.Fatalf("Install should succeed: %v", err)
+	}
+	called, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(called) != "mise install node@20\n" {
+		t.Errorf("Expected mise from ~/.local/bin to be run, got %q", called)
+	}
+}
@@ -0,0 +1,77 @@
+package installer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// brewService is an entry of 'brew services list --json'
+type brewService struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// configureService starts a Homebrew service unless it is already running. A
+// service that failed is restarted instead, and one whose state brew can't tell
+// (such as "unknown", which it reports for some healthy services) is left alone.
+func (i *Installer) configureService(ctx context.Context, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("'service' requires a formula name")
+	}
+
+	status, err := brewServiceStatus(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	var action string
+	switch status {
+	case "started", "scheduled":
+		i.printf("Service %s is already running\n", name)
+		return nil
+	case "none", "stopped":
+		action = "start"
+	case "error":
+		action = "restart"
+	default:
+		i.printf("Service %s is %s; leaving it alone\n", name, status)
+		return nil
+	}
+
+	if err := i.runCommand(ctx, "brew", "services", action, name); err != nil {
+		return fmt.Errorf("failed to %s service %s: %w", action, name, err)
+	}
+	if action == "restart" {
+		i.printf("Restarted service %s (was %s)\n", name, status)
+	} else {
+		i.printf("Started service %s\n", name)
+	}
+	return nil
+}
+
+// brewServiceStatus returns the status 'brew services list' reports for a
+// service, such as "started", "none" or "error"
+func brewServiceStatus(ctx context.Context, name string) (string, error) {
+	output, err := exec.CommandContext(ctx, "brew", "services", "list", "--json").Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("failed to list services: %w", err)
+	}
+
+	var services []brewService
+	if err := json.Unmarshal(output, &services); err != nil {
+		return "", fmt.Errorf("failed to parse 'brew services list' output: %w", err)
+	}
+	for _, service := range services {
+		if service.Name == name {
+			return service.Status, nil
+		}
+	}
+	return "", fmt.Errorf("'%s' is not an installed formula with a service", name)
+}
@@ -0,0 +1,61 @@
+package installer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigureService(t *testing.T) {
+	calls := filepath.Join(t.TempDir(), "calls")
+	t.Setenv("FAKE_BREW_CALLS", calls)
+
+	fakeCommands(t, map[string]string{
+		"brew": `
+if [ "$2" = "list" ]; then
+	echo '[{"name":"postgresql@16","status":"started"},{"name":"redis","status":"none"},{"name":"nginx","status":"error"},{"name":"dnsmasq","status":"unknown"}]'
+	exit 0
+fi
+echo "$@" >> "$FAKE_BREW_CALLS"
+`,
+	})
+
+	var output bytes.Buffer
+	installer := New(t.TempDir()).WithOutput(&output)
+
+	err := installer.Configure(context.Background(), []map[string]string{
+		{"service": "postgresql@16"},
+		{"service": "redis"},
+		{"service": "nginx"},
+		{"service": "dnsmasq"},
+	})
+	if err != nil {
+		t.Fatalf("Configure should succeed: %v", err)
+	}
+
+	called, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "services start redis\nservices restart nginx\n"
+	if string(called) != expected {
+		t.Errorf("Only services that are stopped or failed should be started; expected %q, got %q", expected, called)
+	}
+	for _, message := range []string{
+		"Service postgresql@16 is already running",
+		"Started service redis",
+		"Restarted service nginx (was error)",
+		"Service dnsmasq is unknown; leaving it alone",
+	} {
+		if !strings.Contains(output.String(), message) {
+			t.Errorf("Output should contain %q, got:\n%s", message, output.String())
+		}
+	}
+
+	if err := installer.Configure(context.Background(), []map[string]string{{"service": "mysql"}}); err == nil {
+		t.Error("A formula without a service should error")
+	}
+}
@@ -0,0 +1,132 @@
+package installer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"runtime"
+	"strings"
+	"text/template"
+
+	"github.com/cdzombak/mac-install/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// templateData is the data a 'template' configure step renders its template with
+type templateData struct {
+	// Home and Brew are the values of the $HOME and $BREW config variables
+	Home      string
+	Brew      string
+	ConfigDir string
+	// Hostname is the full host name; ShortHostname has the domain removed
+	Hostname      string
+	ShortHostname string
+	// Arch is the CPU architecture as Go names it, "arm64" or "amd64"
+	Arch     string
+	Username string
+	Env      map[string]string
+	// Vars holds the step's 'vars' parameter
+	Vars map[string]any
+}
+
+// newTemplateData gathers the data for rendering a template, including the
+// variables given in the step's 'vars' mapping
+func (i *Installer) newTemplateData(step map[string]string) (templateData, error) {
+	data := templateData{
+		Brew:      config.BrewPrefix(),
+		ConfigDir: i.workDir,
+		Arch:      runtime.GOARCH,
+		Env:       make(map[string]string),
+		Vars:      make(map[string]any),
+	}
+
+	var err error
+	if data.Home, err = os.UserHomeDir(); err != nil {
+		return data, err
+	}
+	if data.Hostname, err = os.Hostname(); err != nil {
+		return data, err
+	}
+	data.ShortHostname, _, _ = strings.Cut(data.Hostname, ".")
+
+	currentUser, err := user.Current()
+	if err != nil {
+		return data, err
+	}
+	data.Username = currentUser.Username
+
+	for _, entry := range os.Environ() {
+		if key, value, ok := strings.Cut(entry, "="); ok {
+			data.Env[key] = value
+		}
+	}
+
+	if vars, hasVars := step["vars"]; hasVars {
+		if err := yaml.Unmarshal([]byte(vars), &data.Vars); err != nil {
+			return data, fmt.Errorf("'vars' must be a mapping: %w", err)
+		}
+	}
+	return data, nil
+}
+
+// configureTemplate renders a text/template file from the config directory to
+// dest, showing the differences from the previous version if it changed
+func (i *Installer) configureTemplate(ctx context.Context, source string, step map[string]string) error {
+	source, err := i.resolveSource(source)
+	if err != nil {
+		return err
+	}
+	dest, err := destFromStep("template", step)
+	if err != nil {
+		return err
+	}
+
+	sourceInfo, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	mode, err := modeFromStep(step, sourceInfo.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	data, err := i.newTemplateData(step)
+	if err != nil {
+		return fmt.Errorf("failed to gather template data: %w", err)
+	}
+	rendered, err := renderTemplate(source, data)
+	if err != nil {
+		return err
+	}
+
+	written, err := i.placeFile(ctx, dest, rendered, mode, true)
+	if err != nil {
+		return fmt.Errorf("failed to write '%s': %w", dest, err)
+	}
+	if written {
+		i.printf("Rendered %s to %s\n", source, dest)
+	}
+	return nil
+}
+
+// renderTemplate renders the template file at path. Referring to a field or
+// variable that doesn't exist is an error, so typos aren't rendered silently.
+func renderTemplate(path string, data templateData) ([]byte, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New(path).Option("missingkey=error").Parse(string(contents))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	return rendered.Bytes(), nil
+}
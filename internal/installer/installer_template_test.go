@@ -0,0 +1,102 @@
+package installer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestConfigureTemplate(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("TEMPLATE_TEST_EDITOR", "vim")
+
+	template := `[user]
+	email = {{ .Vars.email }}
+[core]
+	editor = {{ .Env.TEMPLATE_TEST_EDITOR }}
+# {{ .Arch }} {{ .Home }} {{ if .Username }}user{{ end }} {{ if .ShortHostname }}host{{ end }}
+`
+	if err := os.WriteFile(filepath.Join(configDir, "gitconfig.tmpl"), []byte(template), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(os.Getenv("HOME"), ".gitconfig")
+
+	var output bytes.Buffer
+	installer := New(configDir).WithOutput(&output)
+	step := map[string]string{"template": "gitconfig.tmpl", "dest": dest, "mode": "0600", "vars": "email: me@example.com\n"}
+
+	if err := installer.Configure(context.Background(), []map[string]string{step}); err != nil {
+		t.Fatalf("Template should render: %v", err)
+	}
+
+	expected := "[user]\n\temail = me@example.com\n[core]\n\teditor = vim\n# " + runtime.GOARCH + " " + os.Getenv("HOME") + " user host\n"
+	contents, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, contents)
+	}
+	if info, _ := os.Stat(dest); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %04o", info.Mode().Perm())
+	}
+
+	output.Reset()
+	if err := installer.Configure(context.Background(), []map[string]string{step}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output.String(), "is up to date") {
+		t.Errorf("Rendering unchanged output should be a no-op, output: %s", output.String())
+	}
+
+	// A change is shown as a diff, and the previous file is backed up
+	output.Reset()
+	step["vars"] = "email: new@example.com\n"
+	if err := installer.Configure(context.Background(), []map[string]string{step}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output.String(), "-\temail = me@example.com") || !strings.Contains(output.String(), "+\temail = new@example.com") {
+		t.Errorf("Changed output should be shown as a diff, output: %s", output.String())
+	}
+	if len(backups(t, dest)) != 1 {
+		t.Error("Previous file should be backed up")
+	}
+}
+
+func TestConfigureTemplateErrors(t *testing.T) {
+	configDir := t.TempDir()
+	dest := filepath.Join(t.TempDir(), "out")
+	templates := map[string]string{
+		"missing-var.tmpl": "{{ .Vars.nope }}",
+		"unknown.tmpl":     "{{ .Nope }}",
+		"invalid.tmpl":     "{{ if }}",
+	}
+	for name, contents := range templates {
+		if err := os.WriteFile(filepath.Join(configDir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []map[string]string{
+		{"template": "missing-var.tmpl", "dest": dest},
+		{"template": "unknown.tmpl", "dest": dest},
+		{"template": "invalid.tmpl", "dest": dest},
+		{"template": "missing-var.tmpl", "dest": dest, "vars": "- not a mapping\n"},
+		{"template": "absent.tmpl", "dest": dest},
+	}
+
+	installer := New(configDir).WithOutput(&bytes.Buffer{})
+	for _, step := range tests {
+		if err := installer.Configure(context.Background(), []map[string]string{step}); err == nil {
+			t.Errorf("Step %v should error", step)
+		}
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Error("Failed templates should not write the destination")
+	}
+}
@@ -1,9 +1,20 @@
 package installer
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/cdzombak/mac-install/internal/config"
 )
 
 func TestArtifactExists(t *testing.T) {
@@ -14,12 +25,12 @@ func TestArtifactExists(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if !installer.ArtifactExists(existingFile) {
+	if !installer.ArtifactExists(context.Background(), existingFile) {
 		t.Error("Should detect existing file")
 	}
 
 	nonExistingFile := filepath.Join(tempDir, "does-not-exist.txt")
-	if installer.ArtifactExists(nonExistingFile) {
+	if installer.ArtifactExists(context.Background(), nonExistingFile) {
 		t.Error("Should not detect non-existing file")
 	}
 }
@@ -88,7 +99,7 @@ func TestArtifactExistsWithWildcards(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			result := installer.ArtifactExists(test.pattern)
+			result := installer.ArtifactExists(context.Background(), test.pattern)
 			if result != test.expected {
 				t.Errorf("Pattern '%s': expected %v, got %v", test.pattern, test.expected, result)
 			}
@@ -102,7 +113,7 @@ func TestArtifactExistsWithWildcardErrors(t *testing.T) {
 
 	// Test with invalid pattern that would cause filepath.Glob to error
 	invalidPattern := filepath.Join(tempDir, "[")
-	if installer.ArtifactExists(invalidPattern) {
+	if installer.ArtifactExists(context.Background(), invalidPattern) {
 		t.Error("Should return false for invalid glob pattern")
 	}
 }
@@ -125,7 +136,7 @@ func TestExecuteInstallStep(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		err := installer.executeInstallStep(test.method, "echo test")
+		err := installer.executeInstallStep(context.Background(), test.method, "echo test")
 		if test.shouldError && err == nil {
 			t.Errorf("Method '%s' should have errored but didn't", test.method)
 		}
@@ -138,11 +149,11 @@ func TestExecuteInstallStep(t *testing.T) {
 func TestExecuteConfigStep(t *testing.T) {
 	installer := New(t.TempDir())
 
-	if err := installer.executeConfigStep("run", "echo test"); err != nil {
+	if err := installer.executeConfigStep(context.Background(), "run", "echo test", nil); err != nil {
 		t.Errorf("run command should not error: %v", err)
 	}
 
-	if err := installer.executeConfigStep("unknown", "test"); err == nil {
+	if err := installer.executeConfigStep(context.Background(), "unknown", "test", nil); err == nil {
 		t.Error("unknown method should error")
 	}
 }
@@ -156,7 +167,7 @@ func TestConfigure(t *testing.T) {
 		{"run": "echo success"},
 	}
 
-	if err := installer.Configure(configSteps); err != nil {
+	if err := installer.Configure(context.Background(), configSteps); err != nil {
 		t.Errorf("Configuration with ignore_errors should not fail: %v", err)
 	}
 
@@ -164,7 +175,7 @@ func TestConfigure(t *testing.T) {
 		{"run": "exit 1"},
 	}
 
-	if err := installer.Configure(configStepsWithoutIgnore); err == nil {
+	if err := installer.Configure(context.Background(), configStepsWithoutIgnore); err == nil {
 		t.Error("Configuration without ignore_errors should fail on error")
 	}
 }
@@ -184,11 +195,131 @@ exit 0
 		t.Fatal(err)
 	}
 
-	if err := installer.runScript(scriptFile); err != nil {
+	if err := installer.runScript(context.Background(), scriptFile); err != nil {
 		t.Errorf("Script execution should not error: %v", err)
 	}
 }
 
+func TestTimeoutForStep(t *testing.T) {
+	installer := New(t.TempDir())
+	installer.SetStepTimeout(time.Minute)
+
+	tests := []struct {
+		name        string
+		step        map[string]string
+		expected    time.Duration
+		shouldError bool
+	}{
+		{"default", map[string]string{"run": "true"}, time.Minute, false},
+		{"step override", map[string]string{"run": "true", "timeout": "90s"}, 90 * time.Second, false},
+		{"invalid", map[string]string{"run": "true", "timeout": "soon"}, 0, true},
+		{"zero", map[string]string{"run": "true", "timeout": "0s"}, 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			timeout, err := installer.timeoutForStep(test.step)
+			if test.shouldError {
+				if err == nil {
+					t.Errorf("Expected error for timeout '%s'", test.step["timeout"])
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if timeout != test.expected {
+				t.Errorf("Expected %s, got %s", test.expected, timeout)
+			}
+		})
+	}
+}
+
+func TestInstallStepTimeout(t *testing.T) {
+	installer := New(t.TempDir())
+
+	installSteps := []map[string]string{
+		{"run": "sleep 5", "timeout": "100ms"},
+	}
+
+	start := time.Now()
+	err := installer.Install(context.Background(), installSteps, "/nonexistent")
+	if err == nil {
+		t.Fatal("Step exceeding its timeout should fail")
+	}
+	if !contains(err.Error(), "timed out after 100ms") {
+		t.Errorf("Expected timeout error, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Step should have been killed promptly, took %s", elapsed)
+	}
+}
+
+func TestStepTimeoutTerminatesFirst(t *testing.T) {
+	tempDir := t.TempDir()
+	installer := New(tempDir)
+
+	// SIGKILL can't be trapped, so the trap only runs if SIGTERM is sent first,
+	// as sudo needs to stop what it runs
+	marker := filepath.Join(tempDir, "terminated")
+	installSteps := []map[string]string{
+		{"run": "trap 'touch " + marker + "; exit 1' TERM; sleep 5 & wait", "timeout": "100ms"},
+	}
+
+	if err := installer.Install(context.Background(), installSteps, "/nonexistent"); err == nil {
+		t.Fatal("Step exceeding its timeout should fail")
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("A timed out command should get SIGTERM before being killed: %v", err)
+	}
+}
+
+func TestConfigureCancelled(t *testing.T) {
+	installer := New(t.TempDir())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	configSteps := []map[string]string{
+		{"ignore_errors": "true"},
+		{"run": "true"},
+	}
+
+	// A cancelled run must stop even when errors are being ignored
+	if err := installer.Configure(ctx, configSteps); err == nil {
+		t.Error("Configure with a cancelled context should fail")
+	}
+}
+
+func TestCommandLogging(t *testing.T) {
+	var stdout, log bytes.Buffer
+	installer := New(t.TempDir()).WithOutput(&stdout).WithLog(&log)
+
+	configSteps := []map[string]string{
+		{"ignore_errors": "true"},
+		{"run": "echo hello"},
+		{"run": "echo oops >&2; exit 3"},
+	}
+	if err := installer.Configure(context.Background(), configSteps); err != nil {
+		t.Fatalf("Configure should not fail: %v", err)
+	}
+
+	if !contains(stdout.String(), "hello") {
+		t.Errorf("Output should still reach stdout, got: %s", stdout.String())
+	}
+
+	for _, expected := range []string{
+		`-- configure step run="echo hello"`,
+		"$ sh -c echo hello  (in ",
+		"hello\n[exit 0 after ",
+		"oops\n[exit 3 after ",
+	} {
+		if !contains(log.String(), expected) {
+			t.Errorf("Log should contain %q, got:\n%s", expected, log.String())
+		}
+	}
+}
+
 func TestInstallArchiveValidation(t *testing.T) {
 	installer := New(t.TempDir())
 
@@ -225,7 +356,7 @@ func TestInstallArchiveValidation(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			err := installer.Install(test.installSteps, "/Applications/Test.app")
+			err := installer.Install(context.Background(), test.installSteps, "/Applications/Test.app")
 			
 			if test.shouldError && err == nil {
 				t.Error("Expected error but got none")
@@ -308,12 +439,12 @@ func TestCopyFileOrDirectory(t *testing.T) {
 		t.Fatal(err)
 	}
 	
-	if err := installer.copyFileOrDirectory(srcFile, destFile); err != nil {
+	if err := installer.copyFileOrDirectory(context.Background(), srcFile, destFile); err != nil {
 		t.Errorf("File copy should not error: %v", err)
 	}
 	
 	// Verify file was copied
-	if !installer.ArtifactExists(destFile) {
+	if !installer.ArtifactExists(context.Background(), destFile) {
 		t.Error("Destination file should exist after copy")
 	}
 	
@@ -330,17 +461,82 @@ func TestCopyFileOrDirectory(t *testing.T) {
 		t.Fatal(err)
 	}
 	
-	if err := installer.copyFileOrDirectory(srcDir, destDir); err != nil {
+	if err := installer.copyFileOrDirectory(context.Background(), srcDir, destDir); err != nil {
 		t.Errorf("Directory copy should not error: %v", err)
 	}
 	
 	// Verify directory was copied
 	copiedFile := filepath.Join(destDir, "file_in_dir.txt")
-	if !installer.ArtifactExists(copiedFile) {
+	if !installer.ArtifactExists(context.Background(), copiedFile) {
 		t.Error("File in copied directory should exist")
 	}
 }
 
+func TestTrustOptionsFromStep(t *testing.T) {
+	trust := trustOptionsFromStep(map[string]string{"archive": "https://example.com/App.dmg", "strip_quarantine": "True", "team_id": " ABCDE12345 "})
+	if !trust.stripQuarantine {
+		t.Error("strip_quarantine should be enabled")
+	}
+	if trust.teamID != "ABCDE12345" {
+		t.Errorf("Expected team ID 'ABCDE12345', got '%s'", trust.teamID)
+	}
+
+	trust = trustOptionsFromStep(map[string]string{"archive": "https://example.com/App.dmg"})
+	if trust.stripQuarantine || trust.teamID != "" {
+		t.Error("Trust options should be disabled by default")
+	}
+}
+
+func TestApplyTrustWithoutOptions(t *testing.T) {
+	installer := New(t.TempDir())
+
+	// With no trust options, nothing is run, so this works on any platform
+	if err := installer.applyTrust(context.Background(), filepath.Join(t.TempDir(), "App.app"), trustOptions{}); err != nil {
+		t.Errorf("applyTrust without options should not error: %v", err)
+	}
+}
+
+func TestParseTeamIdentifier(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected string
+	}{
+		{
+			name: "signed app",
+			output: `Executable=/Applications/App.app/Contents/MacOS/App
+Identifier=com.example.App
+Format=app bundle with Mach-O universal (x86_64 arm64)
+Authority=Developer ID Application: Example Inc (ABCDE12345)
+TeamIdentifier=ABCDE12345
+Sealed Resources version=2 rules=13 files=42
+`,
+			expected: "ABCDE12345",
+		},
+		{
+			name: "ad-hoc signed app",
+			output: `Identifier=com.example.App
+Signature=adhoc
+TeamIdentifier=not set
+`,
+			expected: "not set",
+		},
+		{
+			name:     "no team identifier line",
+			output:   "App.app: code object is not signed at all\n",
+			expected: "not set",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result := parseTeamIdentifier(test.output); result != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, result)
+			}
+		})
+	}
+}
+
 func TestCopyDirectoryContents(t *testing.T) {
 	installer := New(t.TempDir())
 	
@@ -379,7 +575,7 @@ func TestCopyDirectoryContents(t *testing.T) {
 	}
 	
 	// Copy directory contents
-	if err := installer.copyDirectoryContents(srcDir, destDir); err != nil {
+	if err := installer.copyDirectoryContents(context.Background(), srcDir, destDir); err != nil {
 		t.Errorf("Directory contents copy should not error: %v", err)
 	}
 	
@@ -388,13 +584,13 @@ func TestCopyDirectoryContents(t *testing.T) {
 	destFile2 := filepath.Join(destDir, "file2.txt")
 	destNestedFile := filepath.Join(destDir, "nested", "nested.txt")
 	
-	if !installer.ArtifactExists(destFile1) {
+	if !installer.ArtifactExists(context.Background(), destFile1) {
 		t.Error("file1.txt should exist in destination")
 	}
-	if !installer.ArtifactExists(destFile2) {
+	if !installer.ArtifactExists(context.Background(), destFile2) {
 		t.Error("file2.txt should exist in destination")
 	}
-	if !installer.ArtifactExists(destNestedFile) {
+	if !installer.ArtifactExists(context.Background(), destNestedFile) {
 		t.Error("nested/nested.txt should exist in destination")
 	}
 	
@@ -405,6 +601,391 @@ func TestCopyDirectoryContents(t *testing.T) {
 	}
 }
 
+func TestFindFilesInDirectory(t *testing.T) {
+	installer := New(t.TempDir())
+
+	tempDir := t.TempDir()
+	for _, path := range []string{"bin/tool", "bin/tool-helper", "share/doc/README", "Fonts/A.ttf", "Fonts/B.ttf"} {
+		fullPath := filepath.Join(tempDir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(path), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		pattern  string
+		expected int
+	}{
+		{"exact base name", "tool", 1},
+		{"base name glob", "tool*", 2},
+		{"extension glob", "*.ttf", 2},
+		{"relative path glob", "bin/*", 2},
+		{"directory match is not descended", "Fonts", 1},
+		{"no match", "*.otf", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			paths, err := installer.findFilesInDirectory(tempDir, test.pattern)
+			if test.expected == 0 {
+				if err == nil {
+					t.Errorf("Expected error for pattern '%s', got %v", test.pattern, paths)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(paths) != test.expected {
+				t.Errorf("Expected %d matches, got %d: %v", test.expected, len(paths), paths)
+			}
+		})
+	}
+}
+
+func TestArchiveOptionsFromStep(t *testing.T) {
+	tests := []struct {
+		name          string
+		step          map[string]string
+		expectedFiles []string
+		expectedStrip int
+		shouldError   bool
+	}{
+		{
+			name: "no file",
+			step: map[string]string{"archive": "https://example.com/a.zip"},
+		},
+		{
+			name:          "single file",
+			step:          map[string]string{"archive": "https://example.com/a.zip", "file": "App.app"},
+			expectedFiles: []string{"App.app"},
+		},
+		{
+			name:          "file list",
+			step:          map[string]string{"archive": "https://example.com/a.zip", "file": config.StepListMarker + "- tool\n- tool-helper\n"},
+			expectedFiles: []string{"tool", "tool-helper"},
+		},
+		{
+			name:          "strip components",
+			step:          map[string]string{"archive": "https://example.com/a.tar.gz", "strip_components": "1"},
+			expectedStrip: 1,
+		},
+		{
+			name:        "invalid strip components",
+			step:        map[string]string{"archive": "https://example.com/a.tar.gz", "strip_components": "-1"},
+			shouldError: true,
+		},
+		{
+			name:        "rename without file",
+			step:        map[string]string{"archive": "https://example.com/a.zip", "rename": "tool"},
+			shouldError: true,
+		},
+		{
+			name:        "rename with multiple files",
+			step:        map[string]string{"archive": "https://example.com/a.zip", "file": config.StepListMarker + "- a\n- b\n", "rename": "tool"},
+			shouldError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts, err := archiveOptionsFromStep(test.step)
+			if test.shouldError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(opts.files, test.expectedFiles) {
+				t.Errorf("Expected files %v, got %v", test.expectedFiles, opts.files)
+			}
+			if opts.stripComponents != test.expectedStrip {
+				t.Errorf("Expected strip_components %d, got %d", test.expectedStrip, opts.stripComponents)
+			}
+		})
+	}
+}
+
+func TestStripComponents(t *testing.T) {
+	tempDir := t.TempDir()
+	root := filepath.Join(tempDir, "extracted")
+	for _, path := range []string{"tool-1.0/bin/tool", "tool-1.0/README"} {
+		fullPath := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(path), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stripped, err := stripComponents(root, 1)
+	if err != nil {
+		t.Fatalf("stripComponents should not error: %v", err)
+	}
+
+	for _, path := range []string{"bin/tool", "README"} {
+		if _, err := os.Stat(filepath.Join(stripped, path)); err != nil {
+			t.Errorf("Expected %s after stripping: %v", path, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(stripped, "tool-1.0")); err == nil {
+		t.Error("Leading directory should have been stripped")
+	}
+}
+
+func TestInstallArchiveWithDest(t *testing.T) {
+	tempDir := t.TempDir()
+	installer := New(tempDir)
+
+	archive := buildTarGz(t, map[string][]byte{
+		"tool-1.0/bin/tool":        []byte("tool"),
+		"tool-1.0/bin/tool-helper": []byte("tool-helper"),
+		"tool-1.0/README":          []byte("readme"),
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(archive)
+	}))
+	defer server.Close()
+
+	destDir := filepath.Join(tempDir, "bin")
+	installSteps := []map[string]string{
+		{
+			"archive":          server.URL + "/tool.tar.gz",
+			"file":             "bin/*",
+			"strip_components": "1",
+			"dest":             destDir,
+		},
+	}
+
+	if err := installer.Install(context.Background(), installSteps, filepath.Join(destDir, "tool")); err != nil {
+		t.Fatalf("Archive installation should not error: %v", err)
+	}
+
+	for _, name := range []string{"tool", "tool-helper"} {
+		if _, err := os.Stat(filepath.Join(destDir, name)); err != nil {
+			t.Errorf("Expected %s in destination: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "README")); err == nil {
+		t.Error("Unselected files should not be copied")
+	}
+
+	// A single file can be renamed as it is installed
+	renameSteps := []map[string]string{
+		{
+			"archive": server.URL + "/tool.tar.gz",
+			"file":    "tool",
+			"rename":  "tool-1.0",
+			"dest":    destDir,
+		},
+	}
+
+	if err := installer.Install(context.Background(), renameSteps, filepath.Join(destDir, "tool-1.0")); err != nil {
+		t.Fatalf("Archive installation with rename should not error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "tool-1.0")); err != nil {
+		t.Errorf("Expected renamed file in destination: %v", err)
+	}
+}
+
+func TestInstallArchiveTeamIDMismatch(t *testing.T) {
+	fakeCommands(t, map[string]string{
+		"codesign": `[ "$1" = "-dv" ] && echo "TeamIdentifier=OTHER12345" >&2
+exit 0
+`,
+	})
+
+	tempDir := t.TempDir()
+	installer := New(tempDir)
+
+	archive := buildTarGz(t, map[string][]byte{
+		"App.app/Contents/Info.plist": []byte("plist"),
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(archive)
+	}))
+	defer server.Close()
+
+	destDir := filepath.Join(tempDir, "Applications")
+	artifact := filepath.Join(destDir, "App.app")
+	for _, step := range []map[string]string{
+		{"archive": server.URL + "/App.tar.gz", "team_id": "ABCDE12345"},
+		{"archive": server.URL + "/App.tar.gz", "file": "App.app", "team_id": "ABCDE12345"},
+	} {
+		err := installer.Install(context.Background(), []map[string]string{step}, artifact)
+		if err == nil || !strings.Contains(err.Error(), "team ID mismatch") {
+			t.Errorf("Step %v: expected a team ID mismatch, got %v", step, err)
+		}
+		if _, err := os.Stat(artifact); err == nil {
+			t.Errorf("Step %v: an app that failed verification should not be installed", step)
+		}
+	}
+
+	step := map[string]string{"archive": server.URL + "/App.tar.gz", "team_id": "OTHER12345"}
+	if err := installer.Install(context.Background(), []map[string]string{step}, artifact); err != nil {
+		t.Fatalf("App with the expected team ID should install: %v", err)
+	}
+	if _, err := os.Stat(artifact); err != nil {
+		t.Errorf("Expected the verified app to be installed: %v", err)
+	}
+}
+
+func TestInstallArchiveTeamIDVerifiesEveryFile(t *testing.T) {
+	fakeCommands(t, map[string]string{
+		"codesign": `[ "$1" = "-dv" ] && echo "TeamIdentifier=OTHER12345" >&2
+exit 0
+`,
+	})
+
+	tempDir := t.TempDir()
+	installer := New(tempDir)
+
+	archive := buildTarGz(t, map[string][]byte{
+		"Vendor/Foo.app/Contents/Info.plist": []byte("plist"),
+		"bin/tool":                           []byte("tool"),
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(archive)
+	}))
+	defer server.Close()
+
+	// Nested apps and files that aren't apps are verified too
+	destDir := filepath.Join(tempDir, "dest")
+	for _, step := range []map[string]string{
+		{"archive": server.URL + "/foo.tar.gz", "team_id": "ABCDE12345", "dest": destDir},
+		{"archive": server.URL + "/foo.tar.gz", "file": "tool", "team_id": "ABCDE12345", "dest": destDir},
+	} {
+		err := installer.Install(context.Background(), []map[string]string{step}, filepath.Join(destDir, "tool"))
+		if err == nil || !strings.Contains(err.Error(), "team ID mismatch") {
+			t.Errorf("Step %v: expected a team ID mismatch, got %v", step, err)
+		}
+	}
+
+	// A team ID that can't be checked against anything is an error
+	toolOnly := buildTarGz(t, map[string][]byte{"bin/tool": []byte("tool")})
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(toolOnly)
+	}))
+	defer server.Close()
+	for _, step := range []map[string]string{
+		{"archive": server.URL + "/tool.tar.gz", "team_id": "OTHER12345", "dest": destDir},
+		{"pkg": "Tool.pkg", "archive": server.URL + "/tool.tar.gz", "team_id": "OTHER12345"},
+	} {
+		if err := installer.Install(context.Background(), []map[string]string{step}, filepath.Join(destDir, "tool")); err == nil {
+			t.Errorf("Step %v: expected an unverifiable team ID to error", step)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "bin", "tool")); err == nil {
+		t.Error("Unverified files should not be installed")
+	}
+}
+
+func TestInstallNestedArchive(t *testing.T) {
+	tempDir := t.TempDir()
+	installer := New(tempDir)
+
+	inner := buildTarGz(t, map[string][]byte{
+		"payload/tool": []byte("tool"),
+	})
+	outer := buildTarGz(t, map[string][]byte{
+		"README":             []byte("readme"),
+		"dist/payload.tgz":   inner,
+		"dist/checksums.txt": []byte("checksums"),
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(outer)
+	}))
+	defer server.Close()
+
+	destDir := filepath.Join(tempDir, "bin")
+	installSteps := []map[string]string{
+		{
+			"archive": server.URL + "/bundle.tar.gz",
+			"inner":   "payload.tgz",
+			"file":    "tool",
+			"dest":    destDir,
+		},
+	}
+
+	if err := installer.Install(context.Background(), installSteps, filepath.Join(destDir, "tool")); err != nil {
+		t.Fatalf("Nested archive installation should not error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(destDir, "tool"))
+	if err != nil || string(content) != "tool" {
+		t.Errorf("Expected tool from inner archive, got %q (%v)", content, err)
+	}
+
+	missingSteps := []map[string]string{
+		{
+			"archive": server.URL + "/bundle.tar.gz",
+			"inner":   "missing.zip",
+			"dest":    destDir,
+		},
+	}
+
+	err = installer.Install(context.Background(), missingSteps, filepath.Join(destDir, "tool"))
+	if err == nil || !contains(err.Error(), "inner archive") {
+		t.Errorf("Expected inner archive error, got: %v", err)
+	}
+}
+
+// buildTarGz returns a gzip-compressed tar archive holding the given files
+func buildTarGz(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gzWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzWriter)
+	for path, content := range files {
+		if err := tarWriter.WriteHeader(&tar.Header{Name: path, Mode: 0755, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestStepValueList(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected []string
+	}{
+		{"App.app", []string{"App.app"}},
+		{config.StepListMarker + "- a\n- b\n", []string{"a", "b"}},
+		{config.StepListMarker + "[a, b]", []string{"a", "b"}},
+		// Only values flattened from a sequence are split
+		{"[a, b]", []string{"[a, b]"}},
+		{"- a\n- b\n", []string{"- a\n- b\n"}},
+		{"a: b", []string{"a: b"}},
+	}
+
+	for _, test := range tests {
+		result := stepValueList(test.value)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("stepValueList(%q): expected %v, got %v", test.value, test.expected, result)
+		}
+	}
+}
+
 func TestExtractArchiveUnsupportedFormat(t *testing.T) {
 	installer := New(t.TempDir())
 	
@@ -420,7 +1001,7 @@ func TestExtractArchiveUnsupportedFormat(t *testing.T) {
 		t.Fatal(err)
 	}
 	
-	err := installer.extractArchive(unsupportedFile, extractDir, "https://example.com/test.rar")
+	err := installer.extractArchive(context.Background(), unsupportedFile, extractDir, "https://example.com/test.rar")
 	if err == nil {
 		t.Error("Should error on unsupported archive format")
 	}
@@ -469,7 +1050,7 @@ func TestExtractArchiveDMGURLDetection(t *testing.T) {
 				t.Fatal(err)
 			}
 			
-			err := installer.extractArchive(dummyFile, extractDir, test.url)
+			err := installer.extractArchive(context.Background(), dummyFile, extractDir, test.url)
 			
 			// We expect an error since we're not providing real archives,
 			// but we can check if the error suggests it tried the right format
@@ -1,6 +1,7 @@
 package installer
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -26,7 +27,7 @@ func TestInstallDL(t *testing.T) {
 		{"dl": server.URL + "/testfile.txt"},
 	}
 
-	err := installer.Install(installSteps, targetFile)
+	err := installer.Install(context.Background(), installSteps, targetFile)
 	if err != nil {
 		t.Fatalf("Install with dl should not error: %v", err)
 	}
@@ -65,7 +66,7 @@ func TestInstallDLWithDirectory(t *testing.T) {
 		{"dl": server.URL + "/testfile.txt"},
 	}
 
-	err := installer.Install(installSteps, targetFile)
+	err := installer.Install(context.Background(), installSteps, targetFile)
 	if err != nil {
 		t.Fatalf("Install with dl to nested directory should not error: %v", err)
 	}
@@ -101,7 +102,7 @@ func TestInstallDLServerError(t *testing.T) {
 		{"dl": server.URL + "/nonexistent.txt"},
 	}
 
-	err := installer.Install(installSteps, targetFile)
+	err := installer.Install(context.Background(), installSteps, targetFile)
 	if err == nil {
 		t.Error("Install with dl should error when server returns 404")
 	}
@@ -1,6 +1,7 @@
 package installer
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
@@ -17,7 +18,7 @@ func TestWorkingDirectory(t *testing.T) {
 
 	// Test run command with working directory
 	testFile := filepath.Join(configDir, "test-run.txt")
-	err := installer.runShellCommand("echo 'test' > test-run.txt")
+	err := installer.runShellCommand(context.Background(), "echo 'test' > test-run.txt")
 	if err != nil {
 		t.Fatalf("Failed to run shell command: %v", err)
 	}
@@ -35,7 +36,7 @@ echo 'script test' > test-script.txt`
 	}
 
 	testScriptFile := filepath.Join(configDir, "test-script.txt")
-	err = installer.runScript(scriptPath)
+	err = installer.runScript(context.Background(), scriptPath)
 	if err != nil {
 		t.Fatalf("Failed to run script: %v", err)
 	}
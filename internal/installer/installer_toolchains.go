@@ -0,0 +1,63 @@
+package installer
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/cdzombak/mac-install/internal/config"
+	"github.com/cdzombak/mac-install/internal/toolchain"
+)
+
+// toolBinary returns the path of a language toolchain's command, looking on
+// PATH and then in the directories it is usually installed to, since a
+// toolchain installed earlier in the same run may not be on PATH yet
+func toolBinary(name string, dirs ...string) string {
+	if path, err := exec.LookPath(name); err == nil {
+		return path
+	}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return name
+}
+
+func homePath(elem ...string) string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(append([]string{home}, elem...)...)
+}
+
+// installCargo installs a crate with 'cargo install', optionally pinned with
+// "@version"
+func (i *Installer) installCargo(ctx context.Context, crate string) error {
+	cargo := toolBinary("cargo", filepath.Join(toolchain.CargoHome(), "bin"), filepath.Join(config.BrewPrefix(), "bin"))
+	return i.runCommand(ctx, cargo, "install", strings.TrimSpace(crate))
+}
+
+// installGo installs a command with 'go install', at the latest version unless
+// the package names one with "@version"
+func (i *Installer) installGo(ctx context.Context, pkg string) error {
+	pkg = strings.TrimSpace(pkg)
+	if !strings.Contains(pkg, "@") {
+		pkg += "@latest"
+	}
+	goBinary := toolBinary("go", filepath.Join(config.BrewPrefix(), "bin"), "/usr/local/go/bin")
+	return i.runCommand(ctx, goBinary, "install", pkg)
+}
+
+// installUVTool installs a Python command-line tool with 'uv tool install'
+func (i *Installer) installUVTool(ctx context.Context, requirement string) error {
+	uv := toolBinary("uv", filepath.Join(config.BrewPrefix(), "bin"), toolchain.LocalBinDir(), filepath.Join(toolchain.CargoHome(), "bin"))
+	return i.runCommand(ctx, uv, "tool", "install", strings.TrimSpace(requirement))
+}
+
+// installPipUser installs a Python package into the user site with pip
+func (i *Installer) installPipUser(ctx context.Context, requirement string) error {
+	python := toolBinary("python3", filepath.Join(config.BrewPrefix(), "bin"), "/usr/bin")
+	return i.runCommand(ctx, python, "-m", "pip", "install", "--user", strings.TrimSpace(requirement))
+}
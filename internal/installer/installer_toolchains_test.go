@@ -0,0 +1,56 @@
+package installer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInstallToolchains(t *testing.T) {
+	calls := filepath.Join(t.TempDir(), "calls")
+	t.Setenv("FAKE_TOOL_CALLS", calls)
+
+	record := `echo "$(basename "$0") $*" >> "$FAKE_TOOL_CALLS"`
+	fakeCommands(t, map[string]string{"cargo": record, "go": record, "uv": record, "python3": record})
+
+	installer := New(t.TempDir())
+	err := installer.Install(context.Background(), []map[string]string{
+		{"cargo": "ripgrep"},
+		{"go_install": "golang.org/x/tools/gopls"},
+		{"go_install": "example.com/tool@v1.2.0"},
+		{"uv_tool": "ruff"},
+		{"pip_user": "httpie"},
+	}, "")
+	if err != nil {
+		t.Fatalf("Install should succeed: %v", err)
+	}
+
+	called, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "cargo install ripgrep\n" +
+		"go install golang.org/x/tools/gopls@latest\n" +
+		"go install example.com/tool@v1.2.0\n" +
+		"uv tool install ruff\n" +
+		"python3 -m pip install --user httpie\n"
+	if string(called) != expected {
+		t.Errorf("Expected calls %q, got %q", expected, called)
+	}
+}
+
+func TestToolBinary(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "cargo"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if path := toolBinary("cargo", "/nonexistent", dir); path != filepath.Join(dir, "cargo") {
+		t.Errorf("Expected the tool from a fallback directory, got '%s'", path)
+	}
+	if path := toolBinary("uv", dir); path != "uv" {
+		t.Errorf("Expected a missing tool's name, got '%s'", path)
+	}
+}
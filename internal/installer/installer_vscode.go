@@ -0,0 +1,103 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/cdzombak/mac-install/internal/config"
+)
+
+// vscodeExtensionArtifact prefixes artifacts naming an editor extension rather
+// than a path: "vscode_extension:<id>", or "vscode_extension:<cli>:<id>" for an
+// editor other than VS Code, such as Cursor or VSCodium
+const vscodeExtensionArtifact = config.ExtensionArtifactPrefix
+
+// defaultEditorCLI is the CLI used for editor extensions unless 'editor' names another
+const defaultEditorCLI = "code"
+
+// parseExtensionArtifact returns the editor CLI and extension ID an artifact
+// names, and whether it names an extension at all
+func parseExtensionArtifact(artifact string) (string, string, bool) {
+	spec, ok := strings.CutPrefix(artifact, vscodeExtensionArtifact)
+	if !ok {
+		return "", "", false
+	}
+	cli, id, hasCLI := strings.Cut(spec, ":")
+	if !hasCLI {
+		cli, id = defaultEditorCLI, spec
+	}
+	return strings.TrimSpace(cli), extensionID(id), true
+}
+
+// extensionID returns an extension ID without a pinned version, such as
+// "ms-python.python" for "ms-python.python@2024.2.1"
+func extensionID(extension string) string {
+	id, _, _ := strings.Cut(strings.TrimSpace(extension), "@")
+	return id
+}
+
+// editorCLI returns the CLI named by a step's 'editor' parameter, or VS Code's
+func editorCLI(step map[string]string) string {
+	if cli := strings.TrimSpace(step["editor"]); cli != "" {
+		return cli
+	}
+	return defaultEditorCLI
+}
+
+// installVSCodeExtension installs an extension, optionally pinned with
+// "@version", with the editor's CLI
+func (i *Installer) installVSCodeExtension(ctx context.Context, extension string, step map[string]string) error {
+	extension = strings.TrimSpace(extension)
+	if extensionID(extension) == "" {
+		return fmt.Errorf("'vscode_extension' requires an extension ID")
+	}
+	cli := editorCLI(step)
+	defer i.extensions.invalidate(cli)
+	return i.runCommand(ctx, cli, "--install-extension", extension)
+}
+
+// extensionCache holds each editor CLI's installed extensions, listed once per
+// run rather than for every artifact check
+type extensionCache struct {
+	mu    sync.Mutex
+	byCLI map[string]map[string]bool
+}
+
+func newExtensionCache() *extensionCache {
+	return &extensionCache{byCLI: make(map[string]map[string]bool)}
+}
+
+// installed reports whether the editor's CLI lists the extension as installed.
+// Extension IDs are case-insensitive.
+func (c *extensionCache) installed(ctx context.Context, cli, id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	extensions, ok := c.byCLI[cli]
+	if !ok {
+		output, err := exec.CommandContext(ctx, cli, "--list-extensions").Output()
+		if err != nil {
+			// Not cached, since the editor may be installed later in the run
+			return false
+		}
+		extensions = make(map[string]bool)
+		for _, line := range strings.Split(string(output), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				extensions[strings.ToLower(line)] = true
+			}
+		}
+		c.byCLI[cli] = extensions
+	}
+	return extensions[strings.ToLower(id)]
+}
+
+// invalidate forgets the editor's extensions, so they are listed again after
+// an install
+func (c *extensionCache) invalidate(cli string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.byCLI, cli)
+}
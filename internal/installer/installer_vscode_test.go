@@ -0,0 +1,76 @@
+package installer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseExtensionArtifact(t *testing.T) {
+	tests := []struct {
+		artifact string
+		cli      string
+		id       string
+		ok       bool
+	}{
+		{"vscode_extension:ms-python.python", "code", "ms-python.python", true},
+		{"vscode_extension:cursor:golang.go", "cursor", "golang.go", true},
+		{"vscode_extension:codium:golang.go@0.41.0", "codium", "golang.go", true},
+		{"/Applications/Visual Studio Code.app", "", "", false},
+	}
+
+	for _, test := range tests {
+		cli, id, ok := parseExtensionArtifact(test.artifact)
+		if cli != test.cli || id != test.id || ok != test.ok {
+			t.Errorf("Artifact %q: expected (%q, %q, %v), got (%q, %q, %v)", test.artifact, test.cli, test.id, test.ok, cli, id, ok)
+		}
+	}
+}
+
+func TestInstallVSCodeExtension(t *testing.T) {
+	extensions := filepath.Join(t.TempDir(), "extensions")
+	t.Setenv("FAKE_EXTENSIONS", extensions)
+
+	// Each fake editor CLI keeps its extensions in its own file
+	editor := `
+case "$1" in
+--list-extensions) basename "$0" >> "$FAKE_EXTENSIONS.listed"; cat "$FAKE_EXTENSIONS.$(basename "$0")" 2>/dev/null ;;
+--install-extension) echo "$2" | cut -d@ -f1 >> "$FAKE_EXTENSIONS.$(basename "$0")" ;;
+esac
+`
+	fakeCommands(t, map[string]string{"code": editor, "cursor": editor})
+	if err := os.WriteFile(extensions+".code", []byte("EditorConfig.EditorConfig\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	installer := New(t.TempDir())
+	if !installer.ArtifactExists(context.Background(), "vscode_extension:editorconfig.editorconfig") {
+		t.Error("Installed extension should exist, ignoring case")
+	}
+	if installer.ArtifactExists(context.Background(), "vscode_extension:cursor:editorconfig.editorconfig") {
+		t.Error("Extension installed in another editor should not exist")
+	}
+
+	err := installer.Install(context.Background(), []map[string]string{
+		{"vscode_extension": "golang.go@0.41.0", "editor": "cursor"},
+	}, "vscode_extension:cursor:golang.go")
+	if err != nil {
+		t.Fatalf("Install should succeed: %v", err)
+	}
+	if !installer.ArtifactExists(context.Background(), "vscode_extension:cursor:golang.go") {
+		t.Error("Extension should exist after installation")
+	}
+	if installer.ArtifactExists(context.Background(), "vscode_extension:golang.go") {
+		t.Error("Extension should only be installed in the given editor")
+	}
+
+	// Each editor's extensions are listed once, and again after an install
+	listed, err := os.ReadFile(extensions + ".listed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(listed) != "code\ncursor\ncursor\n" {
+		t.Errorf("Expected extensions to be listed once per editor and install, got %q", listed)
+	}
+}
@@ -1,7 +1,8 @@
 package orchestrator
 
 import (
-	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -12,23 +13,56 @@ import (
 	"github.com/cdzombak/mac-install/internal/colors"
 	"github.com/cdzombak/mac-install/internal/config"
 	"github.com/cdzombak/mac-install/internal/installer"
+	"github.com/cdzombak/mac-install/internal/runlog"
 	"github.com/cdzombak/mac-install/internal/state"
 )
 
 type Orchestrator struct {
 	config       *config.Config
+	configDir    string
 	installer    *installer.Installer
 	checklist    *checklist.Manager
 	state        *state.Store
 	skipOptional bool
 	onlyTarget   string
+	jobs         int
+	prefetch     bool
+	runLog       *runlog.Log
+	keepGoing    bool
+	recover      bool
+	resume       bool
+	waitLock     bool
+
+	// checkpoint records the run's progress so it can be resumed; it is nil
+	// when only a single target is processed
+	checkpoint *state.Checkpoint
+
+	// preinstalled records software installed ahead of the per-item pass, by the
+	// Homebrew batch or parallel install phase
+	preinstalled map[string]bool
+
+	// failures and failed record software that failed or was skipped when
+	// keepGoing is set; failed is keyed by display name for dependency checks
+	failures []softwareFailure
+	failed   map[string]bool
+
+	// skipped records software the user chose to skip at a recovery prompt,
+	// which is left for a resumed run to try again
+	skipped map[string]bool
 }
 
 func New(cfg *config.Config, configDir string) *Orchestrator {
 	return &Orchestrator{
 		config:    cfg,
+		configDir: configDir,
 		installer: installer.New(configDir),
 		checklist: checklist.New(cfg.Checklist),
+		jobs:      1,
+		prefetch:  true,
+
+		preinstalled: make(map[string]bool),
+		failed:       make(map[string]bool),
+		skipped:      make(map[string]bool),
 	}
 }
 
@@ -40,22 +74,95 @@ func (o *Orchestrator) SetOnlyTarget(target string) {
 	o.onlyTarget = target
 }
 
-func (o *Orchestrator) Run() error {
+func (o *Orchestrator) SetJobs(jobs int) {
+	o.jobs = jobs
+}
+
+func (o *Orchestrator) SetPrefetch(prefetch bool) {
+	o.prefetch = prefetch
+}
+
+// SetKeepGoing makes the run continue past software that fails, skipping only
+// software that depends on it, and report all failures at the end
+func (o *Orchestrator) SetKeepGoing(keepGoing bool) {
+	o.keepGoing = keepGoing
+}
+
+// SetRecover makes a failed step prompt the user to retry it, skip the software,
+// open a shell in the configuration directory, or abort the run
+func (o *Orchestrator) SetRecover(recover bool) {
+	o.recover = recover
+}
+
+// SetResume makes the run continue from the checkpoint left by the previous run,
+// skipping software it completed and reusing its answers to optional prompts
+func (o *Orchestrator) SetResume(resume bool) {
+	o.resume = resume
+}
+
+// SetWaitLock makes the run wait for another running mac-install to finish,
+// instead of failing
+func (o *Orchestrator) SetWaitLock(wait bool) {
+	o.waitLock = wait
+}
+
+func (o *Orchestrator) SetStepTimeout(timeout time.Duration) {
+	o.installer.SetStepTimeout(timeout)
+}
+
+// SetRunLog records command output and per-software headers to l
+func (o *Orchestrator) SetRunLog(l *runlog.Log) {
+	o.runLog = l
+	if l != nil {
+		o.installer.SetLog(l)
+	}
+}
+
+// Run installs and configures all software in the configuration. Cancelling ctx
+// stops the run, killing any running commands, and reports the interrupted item.
+func (o *Orchestrator) Run(ctx context.Context) error {
 	var err error
 	o.state, err = state.NewStore()
 	if err != nil {
 		return fmt.Errorf("failed to initialize state store: %w", err)
 	}
 
+	lock, err := o.acquireLock(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := lock.Release(); err != nil {
+			fmt.Printf("%s\n", colors.Warning(fmt.Sprintf("Could not remove lock file: %v", err)))
+		}
+	}()
+
 	// Handle -only flag
 	if o.onlyTarget != "" {
-		return o.runOnlyTarget()
+		return o.runOnlyTarget(ctx)
 	}
 
-	if err := o.processInternalArtifacts(); err != nil {
+	o.startCheckpoint()
+
+	if err := o.processInternalArtifacts(ctx); err != nil {
+		if interrupted := o.interruption(ctx, "internal requirements"); interrupted != nil {
+			return interrupted
+		}
 		return fmt.Errorf("failed to process internal artifacts: %w", err)
 	}
 
+	stopPrefetch := o.startPrefetch(ctx)
+	defer stopPrefetch()
+
+	o.runHomebrewBatch(ctx)
+	if interrupted := o.interruption(ctx, "Homebrew batch install"); interrupted != nil {
+		return interrupted
+	}
+	o.runParallelInstalls(ctx)
+	if interrupted := o.interruption(ctx, "parallel install"); interrupted != nil {
+		return interrupted
+	}
+
 	for _, group := range o.config.InstallGroups {
 		// Skip optional groups if flag is set
 		if o.skipOptional && group.IsOptional() {
@@ -65,17 +172,44 @@ func (o *Orchestrator) Run() error {
 		fmt.Printf("\n=== %s ===\n", colors.Group(group.Group))
 
 		for _, software := range group.Software {
-			if err := o.processSoftware(software, group.IsOptional()); err != nil {
-				return fmt.Errorf("failed to process %s: %w", software.GetDisplayName(), err)
+			if o.isCompleted(software) {
+				o.skipCompleted(software)
+				continue
+			}
+			if dependency := o.failedDependency(software); dependency != "" {
+				o.skipDependent(software, dependency)
+				continue
+			}
+
+			err := o.processSoftware(ctx, software, group.IsOptional())
+			if interrupted := o.interruption(ctx, software.GetDisplayName()); interrupted != nil {
+				// Failures before the interruption are still worth reporting
+				_ = o.failureSummary()
+				return interrupted
+			}
+			if err != nil {
+				if !o.keepGoing || errors.Is(err, errAborted) {
+					return fmt.Errorf("failed to process %s: %w", software.GetDisplayName(), err)
+				}
+				o.recordFailure(software, err)
+				continue
+			}
+			if !o.skipped[software.GetDisplayName()] {
+				o.markCompleted(software)
 			}
 		}
 	}
 
+	if err := o.failureSummary(); err != nil {
+		return err
+	}
+
+	o.clearCheckpoint()
 	fmt.Printf("\n%s\n", colors.Success("Installation completed successfully!"))
 	return nil
 }
 
-func (o *Orchestrator) processInternalArtifacts() error {
+func (o *Orchestrator) processInternalArtifacts(ctx context.Context) error {
 	if !o.config.RequiresHomebrew() {
 		return nil
 	}
@@ -89,7 +223,7 @@ func (o *Orchestrator) processInternalArtifacts() error {
 
 	for _, group := range internalConfig.InstallGroups {
 		for _, software := range group.Software {
-			if err := o.processSoftware(software, false); err != nil {
+			if err := o.processSoftware(ctx, software, false); err != nil {
 				return fmt.Errorf("failed to process internal artifact %s: %w", software.GetDisplayName(), err)
 			}
 		}
@@ -98,8 +232,9 @@ func (o *Orchestrator) processInternalArtifacts() error {
 	return nil
 }
 
-func (o *Orchestrator) processSoftware(software config.Software, isOptional bool) error {
+func (o *Orchestrator) processSoftware(ctx context.Context, software config.Software, isOptional bool) error {
 	fmt.Printf("\n%s %s%s\n", colors.Info("•"), colors.Software(software.GetDisplayName()), colors.Dim("..."))
+	o.runLog.Software(software.GetDisplayName())
 
 	if isOptional && software.ShouldPersist() && o.state.IsExcluded(software.GetDisplayName()) {
 		exclusionFile := o.state.GetExclusionFilePath(software.GetDisplayName())
@@ -107,10 +242,13 @@ func (o *Orchestrator) processSoftware(software config.Software, isOptional bool
 		return nil
 	}
 
-	artifactExists := o.installer.ArtifactExists(software.Artifact)
+	artifactExists := o.installer.ArtifactExists(ctx, software.Artifact)
 	softwareInstalled := false
 
-	if artifactExists {
+	if artifactExists && o.preinstalled[software.GetDisplayName()] {
+		softwareInstalled = true
+		fmt.Printf("  %s\n", colors.Success("Installed successfully"))
+	} else if artifactExists {
 		fmt.Printf("  %s\n", colors.Success("Already installed"))
 
 		// Check if checklist items exist for this already-installed software
@@ -128,7 +266,7 @@ func (o *Orchestrator) processSoftware(software config.Software, isOptional bool
 				if o.wasInstalledViaHomebrew(software.Install) {
 					packageName := o.getBrewPackageName(software.Install)
 					if packageName != "" {
-						caveats, _ = o.installer.GetBrewCaveats(packageName)
+						caveats, _ = o.installer.GetBrewCaveats(ctx, packageName)
 					}
 				}
 
@@ -140,7 +278,7 @@ func (o *Orchestrator) processSoftware(software config.Software, isOptional bool
 	} else {
 		if len(software.Install) == 0 {
 			if isOptional {
-				shouldInstall, err := o.promptForInstallation(&software)
+				shouldInstall, err := o.promptForInstallation(ctx, &software)
 				if err != nil {
 					return err
 				}
@@ -168,7 +306,7 @@ func (o *Orchestrator) processSoftware(software config.Software, isOptional bool
 		}
 
 		if isOptional {
-			shouldInstall, err := o.promptForInstallation(&software)
+			shouldInstall, err := o.promptForInstallation(ctx, &software)
 			if err != nil {
 				return err
 			}
@@ -187,23 +325,24 @@ func (o *Orchestrator) processSoftware(software config.Software, isOptional bool
 		}
 
 		fmt.Printf("  %s\n", colors.Info("Installing..."))
-		if err := o.installer.Install(software.Install, software.Artifact); err != nil {
-			return err
+		var action recoveryAction
+		if err := o.installerFor(&software, &action).Install(ctx, software.Install, software.Artifact); err != nil {
+			return o.handleRecovery(&software, action, attributeError(err, software))
 		}
 
-		if !o.installer.ArtifactExists(software.Artifact) {
-			return fmt.Errorf("installation completed but artifact %s not found", software.Artifact)
+		if !o.installer.ArtifactExists(ctx, software.Artifact) {
+			return &installer.ArtifactMissingError{Software: software.GetDisplayName(), Artifact: software.Artifact, Inferred: software.InferredArtifact}
 		}
 
 		softwareInstalled = true
 		fmt.Printf("  %s\n", colors.Success("Installed successfully"))
 	}
 
-	if o.installer.ArtifactExists(software.Artifact) && len(software.Configure) > 0 {
+	if o.installer.ArtifactExists(ctx, software.Artifact) && len(software.Configure) > 0 {
 		// If we just installed a .app and have run/script configuration steps, open the app first
 		if softwareInstalled && strings.HasSuffix(software.Artifact, ".app") && o.hasRunOrScriptSteps(software.Configure) {
 			fmt.Printf("  %s\n", colors.Info("Opening application..."))
-			if err := o.openApplication(software.Artifact); err != nil {
+			if err := o.openApplication(ctx, software.Artifact); err != nil {
 				// Don't fail if we can't open the app, just log it
 				fmt.Printf("  %s\n", colors.Warning(fmt.Sprintf("Could not open application: %v", err)))
 			} else {
@@ -212,11 +351,17 @@ func (o *Orchestrator) processSoftware(software config.Software, isOptional bool
 			}
 		}
 
-		fmt.Printf("  %s\n", colors.Info("Configuring..."))
-		if err := o.installer.Configure(software.Configure); err != nil {
-			return err
+		if o.isConfigured(&software) {
+			fmt.Printf("  %s\n", colors.Dim("Configured in previous run"))
+		} else {
+			fmt.Printf("  %s\n", colors.Info("Configuring..."))
+			var action recoveryAction
+			if err := o.installerFor(&software, &action).Configure(ctx, software.Configure); err != nil {
+				return o.handleRecovery(&software, action, attributeError(err, software))
+			}
+			o.markConfigured(&software)
+			fmt.Printf("  %s\n", colors.Success("Configured successfully"))
 		}
-		fmt.Printf("  %s\n", colors.Success("Configured successfully"))
 	}
 
 	if softwareInstalled && len(software.Checklist) > 0 {
@@ -226,7 +371,7 @@ func (o *Orchestrator) processSoftware(software config.Software, isOptional bool
 		if o.wasInstalledViaHomebrew(software.Install) {
 			packageName := o.getBrewPackageName(software.Install)
 			if packageName != "" {
-				caveats, _ = o.installer.GetBrewCaveats(packageName)
+				caveats, _ = o.installer.GetBrewCaveats(ctx, packageName)
 			}
 		}
 
@@ -238,21 +383,31 @@ func (o *Orchestrator) processSoftware(software config.Software, isOptional bool
 	return nil
 }
 
-func (o *Orchestrator) promptForInstallation(software *config.Software) (bool, error) {
+func (o *Orchestrator) promptForInstallation(ctx context.Context, software *config.Software) (bool, error) {
 	promptText := fmt.Sprintf("Install %s?", software.GetDisplayName())
 	if software.Note != "" {
 		promptText = fmt.Sprintf("Install %s (%s)?", software.GetDisplayName(), software.Note)
 	}
 	fmt.Printf("  %s (y/N): ", colors.Prompt(promptText))
 
-	reader := bufio.NewReader(os.Stdin)
-	response, err := reader.ReadString('\n')
+	if answer, ok := o.previousAnswer(software); ok {
+		response := "n"
+		if answer {
+			response = "y"
+		}
+		fmt.Printf("%s %s\n", response, colors.Dim("(answered in previous run)"))
+		return answer, nil
+	}
+
+	response, err := readLine(ctx)
 	if err != nil {
 		return false, err
 	}
 
 	response = strings.TrimSpace(strings.ToLower(response))
-	return response == "y" || response == "yes", nil
+	answer := response == "y" || response == "yes"
+	o.recordAnswer(software, answer)
+	return answer, nil
 }
 
 func (o *Orchestrator) wasInstalledViaHomebrew(installSteps []map[string]string) bool {
@@ -288,7 +443,73 @@ func (o *Orchestrator) hasRunOrScriptSteps(configSteps []map[string]string) bool
 	return false
 }
 
-func (o *Orchestrator) openApplication(appPath string) error {
-	cmd := exec.Command("open", "-a", appPath)
+func (o *Orchestrator) openApplication(ctx context.Context, appPath string) error {
+	cmd := exec.CommandContext(ctx, "open", "-a", appPath)
 	return cmd.Run()
 }
+
+// attributeError records which software a StepError or ArtifactMissingError
+// from the installer belongs to, and returns err
+func attributeError(err error, software config.Software) error {
+	var stepErr *installer.StepError
+	if errors.As(err, &stepErr) {
+		stepErr.Software = software.GetDisplayName()
+	}
+	var missingErr *installer.ArtifactMissingError
+	if errors.As(err, &missingErr) {
+		missingErr.Software = software.GetDisplayName()
+	}
+	return err
+}
+
+// readLine reads a line from stdin, giving up with ctx's error if ctx is
+// cancelled first so that Ctrl-C isn't blocked by a pending prompt. Stdin is
+// read a byte at a time, so nothing past the line is consumed from it.
+func readLine(ctx context.Context) (string, error) {
+	type result struct {
+		line string
+		err  error
+	}
+	lines := make(chan result, 1)
+	go func() {
+		var line []byte
+		b := make([]byte, 1)
+		for {
+			n, err := os.Stdin.Read(b)
+			if n > 0 {
+				line = append(line, b[0])
+				if b[0] == '\n' {
+					break
+				}
+			}
+			if err != nil {
+				lines <- result{string(line), err}
+				return
+			}
+		}
+		lines <- result{string(line), nil}
+	}()
+
+	select {
+	case r := <-lines:
+		return r.line, r.err
+	case <-ctx.Done():
+		fmt.Println()
+		return "", ctx.Err()
+	}
+}
+
+// interruption reports, and returns an error for, a run that was interrupted or
+// timed out while processing the named item; it returns nil if ctx is still live
+func (o *Orchestrator) interruption(ctx context.Context, name string) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+
+	reason := "Interrupted"
+	if ctx.Err() == context.DeadlineExceeded {
+		reason = "Timed out"
+	}
+	fmt.Printf("\n%s\n", colors.Error(fmt.Sprintf("%s while processing %s", reason, name)))
+	return fmt.Errorf("%s while processing %s: %w", strings.ToLower(reason), name, ctx.Err())
+}
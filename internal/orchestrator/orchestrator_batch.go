@@ -0,0 +1,98 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cdzombak/mac-install/internal/colors"
+	"github.com/cdzombak/mac-install/internal/config"
+)
+
+// homebrewBatch holds missing software that can be installed together with a
+// single brew invocation per package type
+type homebrewBatch struct {
+	formulae []config.Software
+	casks    []config.Software
+}
+
+// collectHomebrewBatch finds software in required groups whose only install step
+// is a single brew or cask package and whose artifact is missing. Such items have
+// no ordering dependencies on other steps and need no prompt, so they can be
+// installed ahead of the per-item pass.
+func (o *Orchestrator) collectHomebrewBatch(ctx context.Context) homebrewBatch {
+	var batch homebrewBatch
+	seen := make(map[string]bool)
+
+	for _, group := range o.config.InstallGroups {
+		if group.IsOptional() {
+			continue
+		}
+
+		for _, software := range group.Software {
+			if len(software.Install) != 1 || len(software.Install[0]) != 1 || len(software.DependsOn) > 0 {
+				continue
+			}
+
+			for method, value := range software.Install[0] {
+				if (method != "brew" && method != "cask") || seen[method+":"+value] {
+					continue
+				}
+				if o.installer.ArtifactExists(ctx, software.Artifact) {
+					continue
+				}
+
+				seen[method+":"+value] = true
+				if method == "brew" {
+					batch.formulae = append(batch.formulae, software)
+				} else {
+					batch.casks = append(batch.casks, software)
+				}
+			}
+		}
+	}
+
+	return batch
+}
+
+// runHomebrewBatch installs all batchable formulae and casks up front, then
+// verifies each artifact individually. Items whose artifact is present afterward
+// are recorded so processSoftware reports them as newly installed; anything that
+// failed is left for processSoftware to install (and report) on its own.
+func (o *Orchestrator) runHomebrewBatch(ctx context.Context) {
+	batch := o.collectHomebrewBatch(ctx)
+	if len(batch.formulae)+len(batch.casks) < 2 {
+		return
+	}
+
+	fmt.Printf("\n=== %s ===\n", colors.Group("Homebrew Batch Install"))
+	o.runLog.Software("Homebrew Batch Install")
+
+	for _, items := range []struct {
+		software []config.Software
+		cask     bool
+	}{
+		{batch.formulae, false},
+		{batch.casks, true},
+	} {
+		if len(items.software) == 0 {
+			continue
+		}
+
+		packages := make([]string, 0, len(items.software))
+		for _, software := range items.software {
+			packages = append(packages, o.getBrewPackageName(software.Install))
+		}
+
+		if err := o.installer.InstallBrewPackages(ctx, packages, items.cask); err != nil {
+			fmt.Printf("  %s\n", colors.Warning(fmt.Sprintf("Batch install failed (%v); failed items will be retried individually", err)))
+		}
+
+		for _, software := range items.software {
+			if o.installer.ArtifactExists(ctx, software.Artifact) {
+				o.preinstalled[software.GetDisplayName()] = true
+			} else {
+				fmt.Printf("  %s\n", colors.Warning(fmt.Sprintf("%s: artifact %s not found after batch install", software.GetDisplayName(), software.Artifact)))
+			}
+		}
+	}
+}
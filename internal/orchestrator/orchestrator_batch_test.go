@@ -0,0 +1,120 @@
+package orchestrator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cdzombak/mac-install/internal/config"
+)
+
+func TestCollectHomebrewBatch(t *testing.T) {
+	tempDir := t.TempDir()
+	existingArtifact := filepath.Join(tempDir, "existing")
+	if err := os.WriteFile(existingArtifact, []byte("test"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		InstallGroups: []config.InstallGroup{
+			{
+				Group:    "Required Group",
+				Optional: boolPtr(false),
+				Software: []config.Software{
+					{
+						Name:     "Formula",
+						Artifact: filepath.Join(tempDir, "formula"),
+						Install:  []map[string]string{{"brew": "formula"}},
+					},
+					{
+						Name:     "Cask",
+						Artifact: filepath.Join(tempDir, "Cask.app"),
+						Install:  []map[string]string{{"cask": "cask"}},
+					},
+					{
+						Name:     "Already Installed",
+						Artifact: existingArtifact,
+						Install:  []map[string]string{{"brew": "installed"}},
+					},
+					{
+						Name:     "Multiple Steps",
+						Artifact: filepath.Join(tempDir, "multi"),
+						Install:  []map[string]string{{"brew": "multi"}, {"run": "multi --setup"}},
+					},
+					{
+						Name:     "Not Homebrew",
+						Artifact: filepath.Join(tempDir, "npm"),
+						Install:  []map[string]string{{"npm": "npm-package"}},
+					},
+					{
+						Name:     "Duplicate Formula",
+						Artifact: filepath.Join(tempDir, "formula-again"),
+						Install:  []map[string]string{{"brew": "formula"}},
+					},
+				},
+			},
+			{
+				Group:    "Optional Group",
+				Optional: boolPtr(true),
+				Software: []config.Software{
+					{
+						Name:     "Optional Formula",
+						Artifact: filepath.Join(tempDir, "optional"),
+						Install:  []map[string]string{{"brew": "optional"}},
+					},
+				},
+			},
+		},
+	}
+
+	o := New(cfg, tempDir)
+	batch := o.collectHomebrewBatch(context.Background())
+
+	if len(batch.formulae) != 1 || batch.formulae[0].Name != "Formula" {
+		t.Errorf("Expected only 'Formula' in formula batch, got %v", batch.formulae)
+	}
+	if len(batch.casks) != 1 || batch.casks[0].Name != "Cask" {
+		t.Errorf("Expected only 'Cask' in cask batch, got %v", batch.casks)
+	}
+}
+
+func TestProcessSoftwareReportsBatchInstalled(t *testing.T) {
+	tempDir := t.TempDir()
+	checklistFile := filepath.Join(tempDir, "SystemSetup.md")
+
+	artifact := filepath.Join(tempDir, "formula")
+	if err := os.WriteFile(artifact, []byte("test"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Checklist: checklistFile,
+	}
+
+	o := New(cfg, tempDir)
+	if err := o.initializeForTesting(tempDir); err != nil {
+		t.Fatal(err)
+	}
+	o.preinstalled = map[string]bool{"Formula": true}
+
+	software := config.Software{
+		Name:      "Formula",
+		Artifact:  artifact,
+		Install:   []map[string]string{{"brew": "formula"}},
+		Checklist: []string{"Configure formula"},
+	}
+
+	if err := o.processSoftware(context.Background(), software, false); err != nil {
+		t.Fatalf("Process software should not error: %v", err)
+	}
+
+	content, err := os.ReadFile(checklistFile)
+	if err != nil {
+		t.Fatalf("Checklist should be written for batch-installed software: %v", err)
+	}
+	if !strings.Contains(string(content), "- [ ] Configure formula") {
+		t.Error("Checklist items should be added for batch-installed software")
+	}
+}
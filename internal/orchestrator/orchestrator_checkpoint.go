@@ -0,0 +1,106 @@
+package orchestrator
+
+import (
+	"fmt"
+
+	"github.com/cdzombak/mac-install/internal/colors"
+	"github.com/cdzombak/mac-install/internal/config"
+	"github.com/cdzombak/mac-install/internal/state"
+)
+
+// startCheckpoint begins recording the run's progress in the state directory.
+// When resuming, it continues from the saved checkpoint if it was made with the
+// same configuration; otherwise the saved checkpoint is replaced.
+func (o *Orchestrator) startCheckpoint() {
+	o.checkpoint = state.NewCheckpoint(o.configDir)
+	if !o.resume {
+		o.saveCheckpoint()
+		return
+	}
+
+	previous, err := o.state.LoadCheckpoint()
+	switch {
+	case err != nil:
+		fmt.Printf("%s\n", colors.Warning(fmt.Sprintf("Could not read checkpoint, starting from the beginning: %v", err)))
+	case previous == nil:
+		fmt.Printf("%s\n", colors.Info("No checkpoint found, starting from the beginning"))
+	case previous.ConfigDir != o.configDir:
+		fmt.Printf("%s\n", colors.Warning(fmt.Sprintf("Checkpoint is for the configuration in %s, starting from the beginning", previous.ConfigDir)))
+	default:
+		o.checkpoint = previous
+		fmt.Printf("%s\n", colors.Info(fmt.Sprintf("Resuming from checkpoint saved %s (%d software item(s) completed)",
+			previous.UpdatedAt.Format("2006-01-02 15:04:05"), len(previous.Completed))))
+	}
+	o.saveCheckpoint()
+}
+
+// saveCheckpoint writes the checkpoint, if the run is recording one. Failing to
+// save progress only costs the ability to resume, so it doesn't fail the run.
+func (o *Orchestrator) saveCheckpoint() {
+	if o.checkpoint == nil {
+		return
+	}
+	if err := o.state.SaveCheckpoint(o.checkpoint); err != nil {
+		fmt.Printf("  %s\n", colors.Warning(fmt.Sprintf("Could not save checkpoint: %v", err)))
+	}
+}
+
+// clearCheckpoint removes the checkpoint once there is nothing left to resume
+func (o *Orchestrator) clearCheckpoint() {
+	if o.checkpoint == nil {
+		return
+	}
+	if err := o.state.ClearCheckpoint(); err != nil {
+		fmt.Printf("%s\n", colors.Warning(fmt.Sprintf("Could not remove checkpoint: %v", err)))
+	}
+}
+
+func (o *Orchestrator) isCompleted(software config.Software) bool {
+	return o.checkpoint != nil && o.checkpoint.IsCompleted(software.GetDisplayName())
+}
+
+func (o *Orchestrator) markCompleted(software config.Software) {
+	if o.checkpoint == nil {
+		return
+	}
+	o.checkpoint.SetCompleted(software.GetDisplayName())
+	o.saveCheckpoint()
+}
+
+func (o *Orchestrator) isConfigured(software *config.Software) bool {
+	return o.checkpoint != nil && o.checkpoint.IsConfigured(software.GetDisplayName())
+}
+
+func (o *Orchestrator) markConfigured(software *config.Software) {
+	if o.checkpoint == nil {
+		return
+	}
+	o.checkpoint.SetConfigured(software.GetDisplayName())
+	o.saveCheckpoint()
+}
+
+// previousAnswer returns the answer given to the optional install prompt for
+// software earlier in the run, or in the run being resumed
+func (o *Orchestrator) previousAnswer(software *config.Software) (answer bool, ok bool) {
+	if o.checkpoint == nil {
+		return false, false
+	}
+	answer, ok = o.checkpoint.Answers[software.GetDisplayName()]
+	return answer, ok
+}
+
+func (o *Orchestrator) recordAnswer(software *config.Software, answer bool) {
+	if o.checkpoint == nil {
+		return
+	}
+	o.checkpoint.Answers[software.GetDisplayName()] = answer
+	o.saveCheckpoint()
+}
+
+// skipCompleted reports software completed in the run being resumed
+func (o *Orchestrator) skipCompleted(software config.Software) {
+	fmt.Printf("\n%s %s%s\n", colors.Info("•"), colors.Software(software.GetDisplayName()), colors.Dim("..."))
+	fmt.Printf("  %s\n", colors.Dim("Completed in previous run"))
+	o.runLog.Software(software.GetDisplayName())
+	o.runLog.Printf("completed in previous run\n")
+}
@@ -0,0 +1,125 @@
+package orchestrator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cdzombak/mac-install/internal/config"
+	"github.com/cdzombak/mac-install/internal/state"
+)
+
+func TestRunResume(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+
+	cfg := testConfig(tempDir,
+		config.Software{
+			Name:      "Configured",
+			Artifact:  filepath.Join(tempDir, "configured"),
+			Install:   []map[string]string{{"run": "touch configured"}},
+			Configure: []map[string]string{{"run": "echo configured >> configure-runs"}},
+		},
+		config.Software{Name: "Broken", Artifact: filepath.Join(tempDir, "broken"), Install: []map[string]string{{"run": "test -f fixed && touch broken"}}},
+	)
+	cfg.InstallGroups = append([]config.InstallGroup{{
+		Group:    "Optional Group",
+		Optional: boolPtr(true),
+		Software: []config.Software{{Name: "Optional", Artifact: filepath.Join(tempDir, "optional"), Install: []map[string]string{{"run": "test -f fixed && touch optional"}}}},
+	}}, cfg.InstallGroups...)
+
+	withStdin(t, "y\n")
+	o := New(cfg, tempDir)
+	o.SetKeepGoing(true)
+	if err := o.Run(context.Background()); err == nil {
+		t.Fatal("First run should fail")
+	}
+
+	store, err := state.NewStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoint, err := store.LoadCheckpoint()
+	if err != nil || checkpoint == nil {
+		t.Fatalf("Failed run should leave a checkpoint: %v", err)
+	}
+	if !checkpoint.IsCompleted("Configured") || checkpoint.IsCompleted("Optional") || checkpoint.IsCompleted("Broken") {
+		t.Errorf("Unexpected completed software: %v", checkpoint.Completed)
+	}
+	if answer, ok := checkpoint.Answers["Optional"]; !ok || !answer {
+		t.Errorf("Checkpoint should record the answer to the optional prompt: %v", checkpoint.Answers)
+	}
+
+	if err := os.WriteFile(filepath.Join(tempDir, "fixed"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// No input is available, so the optional prompt must reuse the previous answer
+	withStdin(t, "")
+	o = New(cfg, tempDir)
+	o.SetResume(true)
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Resumed run should succeed: %v", err)
+	}
+
+	for _, name := range []string{"optional", "broken"} {
+		if _, err := os.Stat(filepath.Join(tempDir, name)); err != nil {
+			t.Errorf("Resumed run should install %s", name)
+		}
+	}
+
+	runs, err := os.ReadFile(filepath.Join(tempDir, "configure-runs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := strings.Count(string(runs), "configured"); count != 1 {
+		t.Errorf("Software completed before resuming should not be configured again, configured %d times", count)
+	}
+
+	if checkpoint, err := store.LoadCheckpoint(); err != nil || checkpoint != nil {
+		t.Errorf("Successful run should clear the checkpoint, got %+v (%v)", checkpoint, err)
+	}
+}
+
+func TestRunWithoutResumeStartsOver(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+
+	store, err := state.NewStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := state.NewCheckpoint(tempDir)
+	previous.SetCompleted("Configured")
+	previous.Answers["Optional"] = false
+	if err := store.SaveCheckpoint(previous); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := testConfig(tempDir,
+		config.Software{
+			Name:      "Configured",
+			Artifact:  filepath.Join(tempDir, "configured"),
+			Install:   []map[string]string{{"run": "touch configured"}},
+			Configure: []map[string]string{{"run": "echo configured >> configure-runs"}},
+		},
+		config.Software{Name: "Broken", Artifact: filepath.Join(tempDir, "broken"), Install: []map[string]string{{"run": "test -f fixed && touch broken"}}},
+	)
+	cfg.InstallGroups = append([]config.InstallGroup{{
+		Group:    "Optional Group",
+		Optional: boolPtr(true),
+		Software: []config.Software{{Name: "Optional", Artifact: filepath.Join(tempDir, "optional"), Install: []map[string]string{{"run": "test -f fixed && touch optional"}}}},
+	}}, cfg.InstallGroups...)
+
+	withStdin(t, "n\n")
+	o := New(cfg, tempDir)
+	if err := o.Run(context.Background()); err == nil {
+		t.Fatal("Run should fail at Broken")
+	}
+
+	if _, err := os.Stat(filepath.Join(tempDir, "configured")); err != nil {
+		t.Error("Software completed in an earlier run should be processed again without -resume")
+	}
+}
@@ -0,0 +1,93 @@
+package orchestrator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cdzombak/mac-install/internal/colors"
+	"github.com/cdzombak/mac-install/internal/config"
+	"github.com/cdzombak/mac-install/internal/installer"
+)
+
+// summaryStderrLines is how many trailing stderr lines of a failed step are
+// shown in the failure summary
+const summaryStderrLines = 5
+
+// softwareFailure records software that failed, or was skipped because software
+// it depends on failed, during a -keep-going run
+type softwareFailure struct {
+	name      string
+	err       error
+	dependsOn string
+}
+
+// recordFailure notes that software failed so the run can continue without it
+func (o *Orchestrator) recordFailure(software config.Software, err error) {
+	o.failures = append(o.failures, softwareFailure{name: software.GetDisplayName(), err: err})
+	o.failed[software.GetDisplayName()] = true
+
+	fmt.Printf("  %s\n", colors.Error(fmt.Sprintf("Failed: %v", err)))
+	o.runLog.Printf("failed: %v\n", err)
+}
+
+// failedDependency returns the first software that software depends on which
+// failed, was skipped at a recovery prompt, or was itself skipped, in this run,
+// or "" if there is none
+func (o *Orchestrator) failedDependency(software config.Software) string {
+	for _, dependency := range software.DependsOn {
+		if o.failed[dependency] || o.skipped[dependency] {
+			return dependency
+		}
+	}
+	return ""
+}
+
+// skipDependent skips software whose dependency failed or was skipped,
+// recording it so that its own dependents are skipped too
+func (o *Orchestrator) skipDependent(software config.Software, dependency string) {
+	reason := "failed"
+	if o.skipped[dependency] {
+		reason = "was skipped"
+	}
+	fmt.Printf("\n%s %s%s\n", colors.Info("•"), colors.Software(software.GetDisplayName()), colors.Dim("..."))
+	fmt.Printf("  %s\n", colors.Warning(fmt.Sprintf("Skipped: depends on %s, which %s", dependency, reason)))
+	o.runLog.Software(software.GetDisplayName())
+	o.runLog.Printf("skipped: depends on %s, which %s\n", dependency, reason)
+
+	o.failures = append(o.failures, softwareFailure{name: software.GetDisplayName(), dependsOn: dependency})
+	o.failed[software.GetDisplayName()] = true
+}
+
+// failureSummary prints the failures recorded during the run and returns an
+// error listing them, or nil if nothing failed
+func (o *Orchestrator) failureSummary() error {
+	if len(o.failures) == 0 {
+		return nil
+	}
+
+	fmt.Printf("\n=== %s ===\n", colors.Group("Failures"))
+
+	names := make([]string, 0, len(o.failures))
+	for _, failure := range o.failures {
+		names = append(names, failure.name)
+		if failure.err != nil {
+			fmt.Printf("  %s %s: %v\n", colors.Error("✗"), colors.Software(failure.name), failure.err)
+
+			var stepErr *installer.StepError
+			if errors.As(failure.err, &stepErr) {
+				stderr := stepErr.Stderr
+				if len(stderr) > summaryStderrLines {
+					stderr = stderr[len(stderr)-summaryStderrLines:]
+				}
+				for _, line := range stderr {
+					fmt.Printf("      %s %s\n", colors.Dim("│"), line)
+				}
+			}
+		} else {
+			fmt.Printf("  %s %s: %s\n", colors.Warning("-"), colors.Software(failure.name), colors.Dim(fmt.Sprintf("skipped, depends on %s", failure.dependsOn)))
+		}
+	}
+
+	return fmt.Errorf("%d software item(s) failed or were skipped: %s", len(o.failures), strings.Join(names, ", "))
+}
@@ -0,0 +1,63 @@
+package orchestrator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cdzombak/mac-install/internal/config"
+)
+
+func TestRunKeepGoing(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+
+	o := New(testConfig(tempDir,
+		config.Software{Name: "Broken", Artifact: filepath.Join(tempDir, "broken"), Install: []map[string]string{{"run": "exit 1"}}},
+		config.Software{Name: "Dependent", Artifact: filepath.Join(tempDir, "dependent"), Install: []map[string]string{{"run": "touch dependent"}}, DependsOn: []string{"Broken"}},
+		config.Software{Name: "Transitive", Artifact: filepath.Join(tempDir, "transitive"), Install: []map[string]string{{"run": "touch transitive"}}, DependsOn: []string{"Dependent"}},
+		config.Software{Name: "Independent", Artifact: filepath.Join(tempDir, "independent"), Install: []map[string]string{{"run": "touch independent"}}},
+	), tempDir)
+	o.SetKeepGoing(true)
+
+	err := o.Run(context.Background())
+	if err == nil {
+		t.Fatal("Run should fail when software failed")
+	}
+	if !strings.Contains(err.Error(), "3 software item(s) failed or were skipped: Broken, Dependent, Transitive") {
+		t.Errorf("Error should list the failed and skipped software, got: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tempDir, "independent")); err != nil {
+		t.Error("Software after a failure should still be installed")
+	}
+	for _, name := range []string{"dependent", "transitive"} {
+		if _, err := os.Stat(filepath.Join(tempDir, name)); err == nil {
+			t.Errorf("Software depending on a failure should be skipped: %s was installed", name)
+		}
+	}
+
+	if len(o.failures) != 3 || o.failures[0].err == nil || o.failures[1].dependsOn != "Broken" || o.failures[2].dependsOn != "Dependent" {
+		t.Errorf("Unexpected failures recorded: %+v", o.failures)
+	}
+}
+
+func TestRunStopsOnFailureByDefault(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+
+	o := New(testConfig(tempDir,
+		config.Software{Name: "Broken", Artifact: filepath.Join(tempDir, "broken"), Install: []map[string]string{{"run": "exit 1"}}},
+		config.Software{Name: "Independent", Artifact: filepath.Join(tempDir, "independent"), Install: []map[string]string{{"run": "touch independent"}}},
+	), tempDir)
+
+	err := o.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "failed to process Broken") {
+		t.Fatalf("Run should stop at the first failure, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "independent")); err == nil {
+		t.Error("Software after a failure should not be installed without -keep-going")
+	}
+}
@@ -0,0 +1,45 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cdzombak/mac-install/internal/colors"
+	"github.com/cdzombak/mac-install/internal/state"
+)
+
+// lockPollInterval is how often a run waiting for the lock checks it again
+var lockPollInterval = time.Second
+
+// acquireLock takes the run lock, so that overlapping runs don't install software
+// or write the checklist at the same time. If another run holds it, this fails,
+// or with waitLock set, waits for that run to finish.
+func (o *Orchestrator) acquireLock(ctx context.Context) (*state.Lock, error) {
+	waiting := false
+	for {
+		lock, err := o.state.AcquireLock()
+		var heldErr *state.LockHeldError
+		if !errors.As(err, &heldErr) {
+			if err != nil {
+				return nil, fmt.Errorf("failed to acquire lock: %w", err)
+			}
+			return lock, nil
+		}
+
+		if !o.waitLock {
+			return nil, fmt.Errorf("%w (use -wait-lock to wait for it to finish)", heldErr)
+		}
+		if !waiting {
+			fmt.Printf("%s\n", colors.Info(fmt.Sprintf("Waiting for the mac-install run with PID %d to finish...", heldErr.PID)))
+			waiting = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, o.interruption(ctx, "waiting for another run")
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
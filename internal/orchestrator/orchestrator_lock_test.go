@@ -0,0 +1,100 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cdzombak/mac-install/internal/config"
+	"github.com/cdzombak/mac-install/internal/state"
+)
+
+func holdLock(t *testing.T) (*state.Store, *state.Lock) {
+	t.Helper()
+
+	store, err := state.NewStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock, err := store.AcquireLock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, lock
+}
+
+func TestRunRefusesWhileLocked(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+	_, lock := holdLock(t)
+	defer func() { _ = lock.Release() }()
+
+	o := New(testConfig(tempDir, config.Software{
+		Name:     "Tool",
+		Artifact: filepath.Join(tempDir, "tool"),
+		Install:  []map[string]string{{"run": "touch tool"}},
+	}), tempDir)
+	err := o.Run(context.Background())
+
+	var heldErr *state.LockHeldError
+	if !errors.As(err, &heldErr) {
+		t.Fatalf("Expected LockHeldError, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "tool")); err == nil {
+		t.Error("Software should not be installed while another run holds the lock")
+	}
+}
+
+func TestRunWaitsForLock(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+	store, lock := holdLock(t)
+
+	oldInterval := lockPollInterval
+	lockPollInterval = 10 * time.Millisecond
+	defer func() { lockPollInterval = oldInterval }()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = lock.Release()
+	}()
+
+	o := New(testConfig(tempDir, config.Software{
+		Name:     "Tool",
+		Artifact: filepath.Join(tempDir, "tool"),
+		Install:  []map[string]string{{"run": "touch tool"}},
+	}), tempDir)
+	o.SetWaitLock(true)
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run should proceed once the lock is released: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "tool")); err != nil {
+		t.Error("Software should be installed after waiting for the lock")
+	}
+	if _, err := os.Stat(store.GetLockPath()); !os.IsNotExist(err) {
+		t.Error("Run should release the lock when it finishes")
+	}
+}
+
+func TestRunWaitForLockCancelled(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+	_, lock := holdLock(t)
+	defer func() { _ = lock.Release() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	o := New(testConfig(tempDir, config.Software{
+		Name:     "Tool",
+		Artifact: filepath.Join(tempDir, "tool"),
+		Install:  []map[string]string{{"run": "touch tool"}},
+	}), tempDir)
+	o.SetWaitLock(true)
+	if err := o.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Waiting for the lock should stop when the context ends, got %v", err)
+	}
+}
@@ -1,9 +1,8 @@
 package orchestrator
 
 import (
-	"bufio"
+	"context"
 	"fmt"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -17,7 +16,7 @@ type softwareMatch struct {
 	group    config.InstallGroup
 }
 
-func (o *Orchestrator) runOnlyTarget() error {
+func (o *Orchestrator) runOnlyTarget(ctx context.Context) error {
 	// Find all matching software
 	matches := o.findMatchingSoftware(o.onlyTarget)
 
@@ -32,8 +31,7 @@ func (o *Orchestrator) runOnlyTarget() error {
 		}
 		fmt.Printf("\nEnter selection (1-%d): ", len(matches))
 		
-		reader := bufio.NewReader(os.Stdin)
-		input, err := reader.ReadString('\n')
+		input, err := readLine(ctx)
 		if err != nil {
 			return fmt.Errorf("failed to read selection: %w", err)
 		}
@@ -51,7 +49,11 @@ func (o *Orchestrator) runOnlyTarget() error {
 	match := matches[0]
 	fmt.Printf("\n=== %s ===\n", colors.Group("Installing Single Target"))
 	
-	if err := o.processSoftware(match.software, match.group.IsOptional()); err != nil {
+	err := o.processSoftware(ctx, match.software, match.group.IsOptional())
+	if interrupted := o.interruption(ctx, match.software.GetDisplayName()); interrupted != nil {
+		return interrupted
+	}
+	if err != nil {
 		return fmt.Errorf("failed to process %s: %w", match.software.GetDisplayName(), err)
 	}
 
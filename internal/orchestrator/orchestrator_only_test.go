@@ -1,6 +1,7 @@
 package orchestrator
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
@@ -103,7 +104,7 @@ func TestRunOnlyTargetSingleMatch(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	err := o.runOnlyTarget()
+	err := o.runOnlyTarget(context.Background())
 	if err != nil {
 		t.Fatalf("runOnlyTarget should not error: %v", err)
 	}
@@ -149,7 +150,7 @@ func TestRunOnlyTargetNoMatch(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	err := o.runOnlyTarget()
+	err := o.runOnlyTarget(context.Background())
 	if err == nil {
 		t.Error("Expected error when no software matches target")
 	}
@@ -184,7 +185,7 @@ func TestRunOnlyTargetMultipleMatches(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	err := o.runOnlyTarget()
+	err := o.runOnlyTarget(context.Background())
 	if err == nil {
 		t.Error("Expected error when multiple software items match target and no input is provided")
 	}
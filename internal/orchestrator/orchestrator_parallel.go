@@ -0,0 +1,179 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cdzombak/mac-install/internal/colors"
+	"github.com/cdzombak/mac-install/internal/config"
+	"github.com/cdzombak/mac-install/internal/installer"
+)
+
+// parallelLocks maps the install methods allowed in the parallel phase to the
+// package manager lock they share. Methods mapped to "" need no lock. Methods not
+// listed (run, script, pkg) may prompt or depend on other steps, so software
+// using them is always installed sequentially.
+var parallelLocks = map[string]string{
+	"brew":           "brew",
+	"cask":           "brew",
+	"gem":            "brew",
+	"gomod":          "brew",
+	"mas":            "mas",
+	"npm":            "npm",
+	"pipx":           "pipx",
+	"cargo":          "cargo",
+	"go_install":     "go",
+	"uv_tool":        "uv",
+	"pip_user":       "pip",
+	"dl":             "",
+	"archive":        "",
+	"github_release": "",
+}
+
+type parallelResult struct {
+	output string
+	log    []byte
+	err    error
+}
+
+// parallelLocksFor returns the sorted package manager locks needed to install
+// the given steps, and whether the steps may run in the parallel phase at all
+func parallelLocksFor(installSteps []map[string]string) ([]string, bool) {
+	lockSet := make(map[string]bool)
+	for _, step := range installSteps {
+		// Parameters such as 'file' or 'timeout' don't affect eligibility
+		for _, method := range installer.InstallStepMethods(step) {
+			lock, ok := parallelLocks[method]
+			if !ok {
+				return nil, false
+			}
+			if lock != "" {
+				lockSet[lock] = true
+			}
+		}
+	}
+
+	locks := make([]string, 0, len(lockSet))
+	for lock := range lockSet {
+		locks = append(locks, lock)
+	}
+	sort.Strings(locks)
+	return locks, true
+}
+
+// collectParallelInstalls finds missing software in required groups (so no
+// prompt is pending) that can be installed independently of other items, so
+// software with depends_on is left to run in order
+func (o *Orchestrator) collectParallelInstalls(ctx context.Context) []config.Software {
+	var items []config.Software
+	for _, group := range o.config.InstallGroups {
+		if group.IsOptional() {
+			continue
+		}
+
+		for _, software := range group.Software {
+			if len(software.Install) == 0 || len(software.DependsOn) > 0 || o.preinstalled[software.GetDisplayName()] {
+				continue
+			}
+			if _, ok := parallelLocksFor(software.Install); !ok {
+				continue
+			}
+			if o.installer.ArtifactExists(ctx, software.Artifact) {
+				continue
+			}
+			items = append(items, software)
+		}
+	}
+	return items
+}
+
+// runParallelInstalls installs independent software with up to o.jobs workers.
+// Each item's output is captured and printed in configuration order. Configuration
+// and checklist updates still happen in the sequential pass.
+func (o *Orchestrator) runParallelInstalls(ctx context.Context) {
+	if o.jobs <= 1 {
+		return
+	}
+
+	items := o.collectParallelInstalls(ctx)
+	if len(items) < 2 {
+		return
+	}
+
+	fmt.Printf("\n=== %s ===\n", colors.Group(fmt.Sprintf("Parallel Install (%d jobs)", o.jobs)))
+
+	locks := make(map[string]*sync.Mutex)
+	for _, lock := range parallelLocks {
+		if lock != "" {
+			locks[lock] = &sync.Mutex{}
+		}
+	}
+
+	results := make([]parallelResult, len(items))
+	done := make([]chan struct{}, len(items))
+	workers := make(chan struct{}, o.jobs)
+
+	for idx, software := range items {
+		done[idx] = make(chan struct{})
+
+		go func(idx int, software config.Software) {
+			defer close(done[idx])
+
+			workers <- struct{}{}
+			defer func() { <-workers }()
+
+			if err := ctx.Err(); err != nil {
+				results[idx] = parallelResult{err: err}
+				return
+			}
+
+			// Locks are acquired in sorted order, so workers can't deadlock
+			itemLocks, _ := parallelLocksFor(software.Install)
+			for _, lock := range itemLocks {
+				locks[lock].Lock()
+				defer locks[lock].Unlock()
+			}
+
+			// Each item logs to its own buffer, so log entries aren't interleaved
+			var output, log bytes.Buffer
+			itemInstaller := o.installer.WithOutput(&output)
+			if o.runLog != nil {
+				itemInstaller = itemInstaller.WithLog(&log)
+			}
+
+			err := attributeError(itemInstaller.Install(ctx, software.Install, software.Artifact), software)
+			if err == nil && !o.installer.ArtifactExists(ctx, software.Artifact) {
+				err = &installer.ArtifactMissingError{Software: software.GetDisplayName(), Artifact: software.Artifact, Inferred: software.InferredArtifact}
+			}
+			results[idx] = parallelResult{output: output.String(), log: log.Bytes(), err: err}
+		}(idx, software)
+	}
+
+	for idx, software := range items {
+		<-done[idx]
+		result := results[idx]
+
+		fmt.Printf("\n%s %s\n", colors.Info("•"), colors.Software(software.GetDisplayName()))
+		o.runLog.Software(software.GetDisplayName() + " (parallel install)")
+		_, _ = o.runLog.Write(result.log)
+		if output := strings.TrimRight(result.output, "\n"); output != "" {
+			for _, line := range strings.Split(output, "\n") {
+				fmt.Printf("  %s %s\n", colors.Dim("│"), line)
+			}
+		}
+
+		if result.err != nil {
+			// Like the Homebrew batch, a failure is retried in the sequential
+			// pass, where the recovery prompt is available and errors are reported
+			o.runLog.Printf("failed: %v\n", result.err)
+			fmt.Printf("  %s\n", colors.Warning(fmt.Sprintf("Failed (%v); will be retried individually", result.err)))
+		} else {
+			o.preinstalled[software.GetDisplayName()] = true
+			fmt.Printf("  %s\n", colors.Success("Installed successfully"))
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package orchestrator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cdzombak/mac-install/internal/config"
+	"github.com/cdzombak/mac-install/internal/runlog"
+)
+
+func TestParallelLocksFor(t *testing.T) {
+	tests := []struct {
+		name          string
+		installSteps  []map[string]string
+		expectedLocks []string
+		eligible      bool
+	}{
+		{
+			name:          "download needs no lock",
+			installSteps:  []map[string]string{{"dl": "https://example.com/tool"}},
+			expectedLocks: []string{},
+			eligible:      true,
+		},
+		{
+			name:          "archive with parameters",
+			installSteps:  []map[string]string{{"archive": "https://example.com/App.dmg", "file": "App.app"}},
+			expectedLocks: []string{},
+			eligible:      true,
+		},
+		{
+			name:          "homebrew methods share a lock",
+			installSteps:  []map[string]string{{"brew": "tool"}, {"gem": "tool-gem"}},
+			expectedLocks: []string{"brew"},
+			eligible:      true,
+		},
+		{
+			name:          "multiple package managers",
+			installSteps:  []map[string]string{{"npm": "tool"}, {"cask": "tool"}, {"mas": "123"}},
+			expectedLocks: []string{"brew", "mas", "npm"},
+			eligible:      true,
+		},
+		{
+			name:          "language toolchains",
+			installSteps:  []map[string]string{{"cargo": "ripgrep"}, {"go_install": "example.com/tool"}, {"uv_tool": "ruff"}},
+			expectedLocks: []string{"cargo", "go", "uv"},
+			eligible:      true,
+		},
+		{
+			name:          "parameters don't affect eligibility",
+			installSteps:  []map[string]string{{"brew": "tool", "timeout": "10m"}, {"github_release": "vendor/tool", "asset": "tool.zip", "team_id": "ABCDE12345"}},
+			expectedLocks: []string{"brew"},
+			eligible:      true,
+		},
+		{
+			name:         "run steps are sequential",
+			installSteps: []map[string]string{{"brew": "tool"}, {"run": "tool --setup"}},
+			eligible:     false,
+		},
+		{
+			name:         "pkg steps are sequential",
+			installSteps: []map[string]string{{"archive": "https://example.com/Tool.dmg", "pkg": "Tool.pkg"}},
+			eligible:     false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			locks, eligible := parallelLocksFor(test.installSteps)
+			if eligible != test.eligible {
+				t.Fatalf("Expected eligible=%v, got %v", test.eligible, eligible)
+			}
+			if eligible && !reflect.DeepEqual(locks, test.expectedLocks) {
+				t.Errorf("Expected locks %v, got %v", test.expectedLocks, locks)
+			}
+		})
+	}
+}
+
+func TestRunParallelInstalls(t *testing.T) {
+	tempDir := t.TempDir()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Checklist: filepath.Join(tempDir, "SystemSetup.md"),
+		InstallGroups: []config.InstallGroup{
+			{
+				Group:    "Required Group",
+				Optional: boolPtr(false),
+				Software: []config.Software{
+					{
+						Name:     "First",
+						Artifact: filepath.Join(tempDir, "first"),
+						Install:  []map[string]string{{"dl": server.URL + "/first"}},
+					},
+					{
+						Name:     "Second",
+						Artifact: filepath.Join(tempDir, "second"),
+						Install:  []map[string]string{{"dl": server.URL + "/second"}},
+					},
+					{
+						Name:     "Broken",
+						Artifact: filepath.Join(tempDir, "broken"),
+						Install:  []map[string]string{{"dl": server.URL + "/missing"}},
+					},
+					{
+						Name:     "Sequential",
+						Artifact: filepath.Join(tempDir, "sequential"),
+						Install:  []map[string]string{{"run": "touch sequential"}},
+					},
+				},
+			},
+		},
+	}
+
+	runLog, err := runlog.CreateIn(filepath.Join(tempDir, "logs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := New(cfg, tempDir)
+	o.SetJobs(2)
+	o.SetRunLog(runLog)
+	o.runParallelInstalls(context.Background())
+	if err := runLog.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"first", "second"} {
+		if _, err := os.Stat(filepath.Join(tempDir, name)); err != nil {
+			t.Errorf("Expected %s to be installed in parallel phase: %v", name, err)
+		}
+	}
+
+	if !o.preinstalled["First"] || !o.preinstalled["Second"] {
+		t.Errorf("Expected First and Second to be recorded as preinstalled, got %v", o.preinstalled)
+	}
+	if o.preinstalled["Broken"] {
+		t.Error("Broken should not be recorded as preinstalled")
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "sequential")); err == nil {
+		t.Error("Software with run steps should not be installed in the parallel phase")
+	}
+
+	logContent, err := os.ReadFile(runLog.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"==== First (parallel install)",
+		"-- install step dl=\"" + server.URL + "/second\"",
+		"==== Broken (parallel install)",
+		"failed: download installation failed",
+	} {
+		if !strings.Contains(string(logContent), expected) {
+			t.Errorf("Log should contain %q, got:\n%s", expected, logContent)
+		}
+	}
+
+	if err := o.initializeForTesting(tempDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.processSoftware(context.Background(), cfg.InstallGroups[0].Software[2], false); err == nil {
+		t.Error("processSoftware should retry and report the failed install")
+	}
+}
+
+func TestRunParallelInstallsDisabledByDefault(t *testing.T) {
+	tempDir := t.TempDir()
+
+	cfg := &config.Config{
+		InstallGroups: []config.InstallGroup{
+			{
+				Group:    "Required Group",
+				Optional: boolPtr(false),
+				Software: []config.Software{
+					{Name: "First", Artifact: filepath.Join(tempDir, "first"), Install: []map[string]string{{"dl": "http://127.0.0.1:1/first"}}},
+					{Name: "Second", Artifact: filepath.Join(tempDir, "second"), Install: []map[string]string{{"dl": "http://127.0.0.1:1/second"}}},
+				},
+			},
+		},
+	}
+
+	o := New(cfg, tempDir)
+	o.runParallelInstalls(context.Background())
+
+	if len(o.preinstalled) != 0 {
+		t.Error("Parallel phase should not run with the default of one job")
+	}
+}
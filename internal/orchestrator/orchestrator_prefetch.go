@@ -0,0 +1,83 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cdzombak/mac-install/internal/colors"
+	"github.com/cdzombak/mac-install/internal/installer"
+)
+
+// prefetchWorkers limits concurrent background downloads, leaving bandwidth for
+// the installs running in the foreground
+const prefetchWorkers = 2
+
+// prefetchCacheBytes bounds the prefetch cache, so downloads queued far ahead
+// can't fill the disk
+const prefetchCacheBytes = 4 << 30
+
+// collectPrefetchURLs returns the dl, archive and package URLs of missing
+// software in required groups, in configuration order. Optional software isn't
+// prefetched since the user may decline it, nor is software that was excluded
+// or that a resumed run already completed.
+func (o *Orchestrator) collectPrefetchURLs(ctx context.Context) []string {
+	var urls []string
+	for _, group := range o.config.InstallGroups {
+		if group.IsOptional() {
+			continue
+		}
+
+		for _, software := range group.Software {
+			if o.installer.ArtifactExists(ctx, software.Artifact) || o.isCompleted(software) {
+				continue
+			}
+			if o.state != nil && o.state.IsExcluded(software.GetDisplayName()) {
+				continue
+			}
+
+			for _, step := range software.Install {
+				for _, key := range []string{"dl", "archive", "pkg"} {
+					value, ok := step[key]
+					if !ok || !installer.IsRemoteURL(value) {
+						continue
+					}
+					urls = append(urls, value)
+					// An archive step's pkg names a file within the archive
+					break
+				}
+			}
+		}
+	}
+	return urls
+}
+
+// startPrefetch begins downloading upcoming URLs in the background. The returned
+// function stops any outstanding downloads and removes the cache.
+func (o *Orchestrator) startPrefetch(ctx context.Context) func() {
+	if !o.prefetch {
+		return func() {}
+	}
+
+	urls := o.collectPrefetchURLs(ctx)
+	if len(urls) == 0 {
+		return func() {}
+	}
+
+	prefetcher, err := installer.NewPrefetcher(ctx, prefetchWorkers, prefetchCacheBytes)
+	if err != nil {
+		fmt.Printf("%s\n", colors.Warning(fmt.Sprintf("Prefetching disabled: %v", err)))
+		return func() {}
+	}
+	prefetcher.SetLog(o.runLog)
+
+	for _, url := range urls {
+		o.runLog.Printf("prefetch: queued %s\n", url)
+		prefetcher.Prefetch(url)
+	}
+	o.installer.SetPrefetcher(prefetcher)
+
+	return func() {
+		o.installer.SetPrefetcher(nil)
+		prefetcher.Close()
+	}
+}
@@ -0,0 +1,86 @@
+package orchestrator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cdzombak/mac-install/internal/config"
+	"github.com/cdzombak/mac-install/internal/state"
+)
+
+func TestCollectPrefetchURLs(t *testing.T) {
+	tempDir := t.TempDir()
+	existingArtifact := filepath.Join(tempDir, "existing")
+	if err := os.WriteFile(existingArtifact, []byte("test"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		InstallGroups: []config.InstallGroup{
+			{
+				Group:    "Required Group",
+				Optional: boolPtr(false),
+				Software: []config.Software{
+					{
+						Artifact: filepath.Join(tempDir, "tool"),
+						Install:  []map[string]string{{"dl": "https://example.com/tool"}},
+					},
+					{
+						Artifact: filepath.Join(tempDir, "App.app"),
+						Install:  []map[string]string{{"archive": "https://example.com/App.dmg", "pkg": "Install App.pkg"}},
+					},
+					{
+						Artifact: filepath.Join(tempDir, "Agent"),
+						Install:  []map[string]string{{"pkg": "https://example.com/Agent.pkg"}},
+					},
+					{
+						Artifact: filepath.Join(tempDir, "Local"),
+						Install:  []map[string]string{{"pkg": "pkgs/Local.pkg"}},
+					},
+					{
+						Artifact: existingArtifact,
+						Install:  []map[string]string{{"dl": "https://example.com/existing"}},
+					},
+				},
+			},
+			{
+				Group:    "Optional Group",
+				Optional: boolPtr(true),
+				Software: []config.Software{
+					{
+						Artifact: filepath.Join(tempDir, "optional"),
+						Install:  []map[string]string{{"dl": "https://example.com/optional"}},
+					},
+				},
+			},
+		},
+	}
+
+	o := New(cfg, tempDir)
+	expected := []string{
+		"https://example.com/tool",
+		"https://example.com/App.dmg",
+		"https://example.com/Agent.pkg",
+	}
+	if urls := o.collectPrefetchURLs(context.Background()); !reflect.DeepEqual(urls, expected) {
+		t.Errorf("Expected %v, got %v", expected, urls)
+	}
+
+	// Excluded software and software a resumed run completed aren't prefetched
+	t.Setenv("HOME", tempDir)
+	if err := o.initializeForTesting(tempDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.state.SetExcluded(cfg.InstallGroups[0].Software[1].GetDisplayName()); err != nil {
+		t.Fatal(err)
+	}
+	o.checkpoint = &state.Checkpoint{}
+	o.checkpoint.SetCompleted(cfg.InstallGroups[0].Software[0].GetDisplayName())
+	expected = []string{"https://example.com/Agent.pkg"}
+	if urls := o.collectPrefetchURLs(context.Background()); !reflect.DeepEqual(urls, expected) {
+		t.Errorf("Expected %v, got %v", expected, urls)
+	}
+}
@@ -0,0 +1,106 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/cdzombak/mac-install/internal/colors"
+	"github.com/cdzombak/mac-install/internal/config"
+	"github.com/cdzombak/mac-install/internal/installer"
+)
+
+// errAborted marks a failure the user chose to abort the run on, which stops
+// the run even with -keep-going
+var errAborted = errors.New("aborted by user")
+
+// recoveryAction is the user's choice after a step fails
+type recoveryAction int
+
+const (
+	recoveryNone recoveryAction = iota
+	recoveryRetry
+	recoverySkip
+	recoveryAbort
+)
+
+// installerFor returns the installer to use for software. When recovery prompts
+// are enabled, failed steps prompt the user, and the final choice is stored in
+// action for handleRecovery.
+func (o *Orchestrator) installerFor(software *config.Software, action *recoveryAction) *installer.Installer {
+	if !o.recover {
+		return o.installer
+	}
+	return o.installer.WithFailureHandler(func(ctx context.Context, err *installer.StepError) bool {
+		*action = o.promptForRecovery(ctx, software, err)
+		return *action == recoveryRetry
+	})
+}
+
+// handleRecovery applies the user's recovery choice to the error from a failed
+// install or configure phase, returning nil if the user chose to skip the software
+func (o *Orchestrator) handleRecovery(software *config.Software, action recoveryAction, err error) error {
+	switch action {
+	case recoverySkip:
+		o.skipped[software.GetDisplayName()] = true
+		fmt.Printf("  %s\n", colors.Dim("Skipped"))
+		return nil
+	case recoveryAbort:
+		return fmt.Errorf("%w: %w", errAborted, err)
+	default:
+		return err
+	}
+}
+
+// promptForRecovery asks the user how to proceed after a step fails, opening a
+// shell in the configuration directory as often as asked before deciding
+func (o *Orchestrator) promptForRecovery(ctx context.Context, software *config.Software, stepErr *installer.StepError) recoveryAction {
+	fmt.Printf("  %s\n", colors.Error(fmt.Sprintf("Step failed: %v", stepErr)))
+
+	for {
+		promptText := fmt.Sprintf("[r]etry the step, [s]kip %s, open a [sh]ell, or [a]bort?", software.GetDisplayName())
+		fmt.Printf("  %s (r/s/sh/a): ", colors.Prompt(promptText))
+
+		response, err := readLine(ctx)
+		if err != nil {
+			return recoveryAbort
+		}
+
+		switch strings.TrimSpace(strings.ToLower(response)) {
+		case "r", "retry":
+			fmt.Printf("  %s\n", colors.Info("Retrying..."))
+			return recoveryRetry
+		case "s", "skip":
+			return recoverySkip
+		case "a", "abort":
+			return recoveryAbort
+		case "sh", "shell":
+			if err := o.openRecoveryShell(ctx); err != nil {
+				fmt.Printf("  %s\n", colors.Warning(fmt.Sprintf("Shell exited with error: %v", err)))
+			}
+		default:
+			fmt.Printf("  %s\n", colors.Warning("Please enter r, s, sh or a"))
+		}
+	}
+}
+
+// openRecoveryShell runs the user's shell interactively in the configuration
+// directory, returning when it exits
+func (o *Orchestrator) openRecoveryShell(ctx context.Context) error {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+
+	fmt.Printf("  %s\n", colors.Info(fmt.Sprintf("Opening %s in %s; exit the shell to return to mac-install", shell, o.configDir)))
+
+	cmd := exec.CommandContext(ctx, shell)
+	cmd.Dir = o.configDir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,176 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cdzombak/mac-install/internal/config"
+)
+
+// withStdin replaces os.Stdin with a pipe supplying input for the test's duration
+func withStdin(t *testing.T, input string) {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		defer func() { _ = w.Close() }()
+		_, _ = w.WriteString(input)
+	}()
+
+	oldStdin := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() {
+		os.Stdin = oldStdin
+		_ = r.Close()
+	})
+}
+
+// testConfig returns a configuration with its checklist in tempDir and the given
+// software in one required group
+func testConfig(tempDir string, software ...config.Software) *config.Config {
+	return &config.Config{
+		Checklist: filepath.Join(tempDir, "SystemSetup.md"),
+		InstallGroups: []config.InstallGroup{
+			{Group: "Required Group", Optional: boolPtr(false), Software: software},
+		},
+	}
+}
+
+func newRecoveryTestOrchestrator(t *testing.T, tempDir string) *Orchestrator {
+	t.Helper()
+
+	o := New(&config.Config{Checklist: filepath.Join(tempDir, "SystemSetup.md")}, tempDir)
+	o.SetRecover(true)
+	if err := o.initializeForTesting(tempDir); err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func TestRecoveryRetry(t *testing.T) {
+	tempDir := t.TempDir()
+	o := newRecoveryTestOrchestrator(t, tempDir)
+	withStdin(t, "huh\nr\n")
+
+	// Fails the first time, then succeeds once the marker exists
+	software := config.Software{
+		Name:     "Flaky",
+		Artifact: filepath.Join(tempDir, "installed"),
+		Install:  []map[string]string{{"run": "if [ -f attempted ]; then touch installed; else touch attempted; exit 1; fi"}},
+	}
+
+	if err := o.processSoftware(context.Background(), software, false); err != nil {
+		t.Fatalf("Retried step should succeed: %v", err)
+	}
+	if _, err := os.Stat(software.Artifact); err != nil {
+		t.Error("Artifact should exist after retry")
+	}
+}
+
+func TestRecoverySkip(t *testing.T) {
+	tempDir := t.TempDir()
+	o := newRecoveryTestOrchestrator(t, tempDir)
+	withStdin(t, "s\n")
+
+	software := config.Software{
+		Name:      "Broken",
+		Artifact:  filepath.Join(tempDir, "broken"),
+		Install:   []map[string]string{{"run": "exit 1"}},
+		Checklist: []string{"Should not be added"},
+	}
+
+	if err := o.processSoftware(context.Background(), software, false); err != nil {
+		t.Fatalf("Skipped software should not fail: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "SystemSetup.md")); err == nil {
+		t.Error("Skipped software should not add checklist items")
+	}
+}
+
+func TestRecoveryShellThenAbort(t *testing.T) {
+	tempDir := t.TempDir()
+	o := newRecoveryTestOrchestrator(t, tempDir)
+	withStdin(t, "sh\na\n")
+
+	// The "shell" records where it ran, without reading stdin
+	shell := filepath.Join(t.TempDir(), "shell")
+	if err := os.WriteFile(shell, []byte("#!/bin/sh\npwd > shell-dir\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SHELL", shell)
+
+	software := config.Software{
+		Name:      "Broken",
+		Artifact:  filepath.Join(tempDir, "broken"),
+		Configure: []map[string]string{{"run": "exit 1"}},
+	}
+	if err := os.WriteFile(software.Artifact, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := o.processSoftware(context.Background(), software, false)
+	if !errors.Is(err, errAborted) {
+		t.Fatalf("Expected abort error, got: %v", err)
+	}
+
+	shellDir, err := os.ReadFile(filepath.Join(tempDir, "shell-dir"))
+	if err != nil {
+		t.Fatalf("Shell should have run in the config directory: %v", err)
+	}
+	if resolved, _ := filepath.EvalSymlinks(tempDir); strings.TrimSpace(string(shellDir)) != resolved {
+		t.Errorf("Expected shell in %s, got %s", resolved, shellDir)
+	}
+}
+
+func TestRecoveryAbortStopsKeepGoing(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+	withStdin(t, "a\n")
+
+	o := New(testConfig(tempDir,
+		config.Software{Name: "Broken", Artifact: filepath.Join(tempDir, "broken"), Install: []map[string]string{{"run": "exit 1"}}},
+		config.Software{Name: "Independent", Artifact: filepath.Join(tempDir, "independent"), Install: []map[string]string{{"run": "touch independent"}}},
+	), tempDir)
+	o.SetKeepGoing(true)
+	o.SetRecover(true)
+
+	err := o.Run(context.Background())
+	if !errors.Is(err, errAborted) {
+		t.Fatalf("Abort should stop a -keep-going run, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "independent")); err == nil {
+		t.Error("Software after an abort should not be installed")
+	}
+}
+
+func TestRecoverySkipSkipsDependents(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+	withStdin(t, "s\n")
+
+	o := New(testConfig(tempDir,
+		config.Software{Name: "Broken", Artifact: filepath.Join(tempDir, "broken"), Install: []map[string]string{{"run": "exit 1"}}},
+		config.Software{Name: "Dependent", Artifact: filepath.Join(tempDir, "dependent"), Install: []map[string]string{{"run": "touch dependent"}}, DependsOn: []string{"Broken"}},
+		config.Software{Name: "Independent", Artifact: filepath.Join(tempDir, "independent"), Install: []map[string]string{{"run": "touch independent"}}},
+	), tempDir)
+	o.SetKeepGoing(true)
+	o.SetRecover(true)
+
+	err := o.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "1 software item(s) failed or were skipped: Dependent") {
+		t.Fatalf("Run should report the skipped dependent, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "dependent")); err == nil {
+		t.Error("Software depending on skipped software should not be installed")
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "independent")); err != nil {
+		t.Error("Software after a skip should still be installed")
+	}
+}
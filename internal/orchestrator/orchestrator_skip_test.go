@@ -1,6 +1,7 @@
 package orchestrator
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
@@ -55,7 +56,7 @@ func TestSkipOptional(t *testing.T) {
 			continue
 		}
 		for _, software := range group.Software {
-			err := o.processSoftware(software, group.IsOptional())
+			err := o.processSoftware(context.Background(), software, group.IsOptional())
 			if err != nil {
 				t.Fatalf("Process software should not error: %v", err)
 			}
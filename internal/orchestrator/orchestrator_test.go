@@ -1,12 +1,16 @@
 package orchestrator
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/cdzombak/mac-install/internal/config"
+	"github.com/cdzombak/mac-install/internal/installer"
 	"github.com/cdzombak/mac-install/internal/state"
 )
 
@@ -130,7 +134,7 @@ func TestProcessSoftwareWithoutInstallSteps(t *testing.T) {
 		Artifact: "/nonexistent/Test.app",
 	}
 
-	err := o.processSoftware(software, false)
+	err := o.processSoftware(context.Background(), software, false)
 	if err != nil {
 		t.Fatalf("Process software should not error: %v", err)
 	}
@@ -184,7 +188,7 @@ func TestProcessOptionalSoftwareWithoutInstallStepsDeclines(t *testing.T) {
 		Artifact: "/nonexistent/OptionalTest.app",
 	}
 
-	err = o.processSoftware(software, true) // isOptional = true
+	err = o.processSoftware(context.Background(), software, true) // isOptional = true
 	if err != nil {
 		t.Fatalf("Process software should not error: %v", err)
 	}
@@ -240,7 +244,7 @@ func TestProcessOptionalSoftwareWithoutInstallStepsAccepts(t *testing.T) {
 		Artifact: "/nonexistent/OptionalTest.app",
 	}
 
-	err = o.processSoftware(software, true) // isOptional = true
+	err = o.processSoftware(context.Background(), software, true) // isOptional = true
 	if err != nil {
 		t.Fatalf("Process software should not error: %v", err)
 	}
@@ -348,7 +352,7 @@ func TestProcessSoftwareExistingWithMissingChecklist(t *testing.T) {
 		Checklist: []string{"Manual step 1", "Manual step 2"},
 	}
 
-	err := o.processSoftware(software, true)
+	err := o.processSoftware(context.Background(), software, true)
 	if err != nil {
 		t.Fatalf("Process software should not error: %v", err)
 	}
@@ -402,7 +406,7 @@ func TestProcessSoftwareWithoutNameUsesArtifactDisplayName(t *testing.T) {
 		Checklist: []string{"Manual step 1", "Manual step 2"},
 	}
 
-	err := o.processSoftware(software, true)
+	err := o.processSoftware(context.Background(), software, true)
 	if err != nil {
 		t.Fatalf("Process software should not error: %v", err)
 	}
@@ -426,6 +430,80 @@ func TestProcessSoftwareWithoutNameUsesArtifactDisplayName(t *testing.T) {
 	}
 }
 
+func TestProcessSoftwareErrorTypes(t *testing.T) {
+	tempDir := t.TempDir()
+	o := New(&config.Config{Checklist: filepath.Join(tempDir, "SystemSetup.md")}, tempDir)
+	if err := o.initializeForTesting(tempDir); err != nil {
+		t.Fatal(err)
+	}
+
+	failing := config.Software{
+		Name:     "Failing",
+		Artifact: filepath.Join(tempDir, "failing"),
+		Install:  []map[string]string{{"run": "exit 4"}},
+	}
+	var stepErr *installer.StepError
+	if err := o.processSoftware(context.Background(), failing, false); !errors.As(err, &stepErr) {
+		t.Fatalf("Expected StepError, got %T: %v", err, err)
+	}
+	if stepErr.Software != "Failing" || stepErr.ExitCode != 4 {
+		t.Errorf("Unexpected step error details: %+v", stepErr)
+	}
+
+	missing := config.Software{
+		Name:     "Missing",
+		Artifact: filepath.Join(tempDir, "missing"),
+		Install:  []map[string]string{{"run": "true"}},
+	}
+	var missingErr *installer.ArtifactMissingError
+	if err := o.processSoftware(context.Background(), missing, false); !errors.As(err, &missingErr) {
+		t.Fatalf("Expected ArtifactMissingError, got %T: %v", err, err)
+	}
+	if missingErr.Software != "Missing" || missingErr.Artifact != missing.Artifact {
+		t.Errorf("Unexpected artifact error details: %+v", missingErr)
+	}
+	if strings.Contains(missingErr.Error(), "set 'artifact'") {
+		t.Errorf("A given artifact should not suggest setting one: %v", missingErr)
+	}
+
+	// An inferred artifact may name the wrong command, which the error points out
+	missing.InferredArtifact = true
+	if err := o.processSoftware(context.Background(), missing, false); !errors.As(err, &missingErr) || !missingErr.Inferred {
+		t.Fatalf("Expected ArtifactMissingError for an inferred artifact, got %T: %v", err, err)
+	}
+	if !strings.Contains(missingErr.Error(), "set 'artifact' if the command is named differently") {
+		t.Errorf("Error should suggest setting the artifact, got: %v", missingErr)
+	}
+}
+
+func TestInterruption(t *testing.T) {
+	o := &Orchestrator{}
+
+	if err := o.interruption(context.Background(), "Test Software"); err != nil {
+		t.Errorf("Live context should not report an interruption: %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := o.interruption(cancelled, "Test Software")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "interrupted while processing Test Software") {
+		t.Errorf("Error should name the interrupted item: %v", err)
+	}
+
+	expired, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	err = o.interruption(expired, "Test Software")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "timed out while processing Test Software") {
+		t.Errorf("Error should name the timed-out item: %v", err)
+	}
+}
+
 func (o *Orchestrator) initializeForTesting(tempDir string) error {
 	var err error
 	o.state, err = state.NewStore()
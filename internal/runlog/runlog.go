@@ -0,0 +1,83 @@
+package runlog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Log is a per-run log file recording the output of every command the run
+// executes, so failures can be diagnosed after the terminal scrollback is gone.
+// All methods are safe for concurrent use, and do nothing on a nil *Log.
+type Log struct {
+	mu   sync.Mutex
+	file *os.File
+	path string
+}
+
+// Create opens a new timestamped log file under ~/.config/dotfiles/logs
+func Create() (*Log, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	return CreateIn(filepath.Join(homeDir, ".config", "dotfiles", "logs"))
+}
+
+// CreateIn opens a new timestamped log file in dir, creating dir if needed
+func CreateIn(dir string) (*Log, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	path := filepath.Join(dir, fmt.Sprintf("mac-install-%s.log", now.Format("20060102-150405")))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Log{file: file, path: path}
+	l.Printf("mac-install run started %s\n", now.Format(time.RFC3339))
+	return l, nil
+}
+
+// Path returns the log file's path, or "" for a nil log
+func (l *Log) Path() string {
+	if l == nil {
+		return ""
+	}
+	return l.path
+}
+
+// Write appends p to the log
+func (l *Log) Write(p []byte) (int, error) {
+	if l == nil {
+		return len(p), nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Write(p)
+}
+
+// Printf appends a formatted message to the log
+func (l *Log) Printf(format string, args ...interface{}) {
+	_, _ = fmt.Fprintf(l, format, args...)
+}
+
+// Software writes a header marking the start of work on the named software
+func (l *Log) Software(name string) {
+	l.Printf("\n==== %s (%s) ====\n", name, time.Now().Format(time.TimeOnly))
+}
+
+// Close closes the log file
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
@@ -0,0 +1,73 @@
+package runlog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCreate(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+
+	l, err := Create()
+	if err != nil {
+		t.Fatalf("Failed to create log: %v", err)
+	}
+	defer func() {
+		_ = l.Close()
+	}()
+
+	expectedDir := filepath.Join(homeDir, ".config", "dotfiles", "logs")
+	if filepath.Dir(l.Path()) != expectedDir {
+		t.Errorf("Expected log in '%s', got '%s'", expectedDir, l.Path())
+	}
+	if !strings.HasPrefix(filepath.Base(l.Path()), "mac-install-") || !strings.HasSuffix(l.Path(), ".log") {
+		t.Errorf("Unexpected log file name: %s", filepath.Base(l.Path()))
+	}
+}
+
+func TestLogContents(t *testing.T) {
+	l, err := CreateIn(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create log: %v", err)
+	}
+
+	l.Software("Test Software")
+	if _, err := l.Write([]byte("command output\n")); err != nil {
+		t.Fatal(err)
+	}
+	l.Printf("exit %d\n", 1)
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(l.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"mac-install run started", "==== Test Software (", "command output\n", "exit 1\n"} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("Log should contain %q, got:\n%s", expected, content)
+		}
+	}
+}
+
+func TestNilLog(t *testing.T) {
+	var l *Log
+
+	// A nil log discards everything, so callers needn't check for one
+	l.Software("Test Software")
+	l.Printf("ignored\n")
+	if n, err := l.Write([]byte("ignored")); n != 7 || err != nil {
+		t.Errorf("Write on nil log should succeed, got %d, %v", n, err)
+	}
+	if l.Path() != "" {
+		t.Errorf("Nil log should have no path, got '%s'", l.Path())
+	}
+	if err := l.Close(); err != nil {
+		t.Errorf("Close on nil log should succeed: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+const checkpointFilename = "mac-install-checkpoint.json"
+
+// Checkpoint records the progress of a run, so that a run which failed or was
+// interrupted can be resumed without redoing work or asking questions again.
+// Software is identified by display name.
+type Checkpoint struct {
+	ConfigDir string `json:"config_dir"`
+	// Completed lists software that was fully processed
+	Completed []string `json:"completed"`
+	// Configured lists software whose configure steps all succeeded
+	Configured []string `json:"configured"`
+	// Answers holds the answers given to optional install prompts
+	Answers   map[string]bool `json:"answers"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// NewCheckpoint returns an empty checkpoint for a run using the configuration in configDir
+func NewCheckpoint(configDir string) *Checkpoint {
+	return &Checkpoint{ConfigDir: configDir, Answers: make(map[string]bool)}
+}
+
+func (c *Checkpoint) IsCompleted(softwareName string) bool {
+	return slices.Contains(c.Completed, softwareName)
+}
+
+func (c *Checkpoint) SetCompleted(softwareName string) {
+	if !c.IsCompleted(softwareName) {
+		c.Completed = append(c.Completed, softwareName)
+	}
+}
+
+func (c *Checkpoint) IsConfigured(softwareName string) bool {
+	return slices.Contains(c.Configured, softwareName)
+}
+
+func (c *Checkpoint) SetConfigured(softwareName string) {
+	if !c.IsConfigured(softwareName) {
+		c.Configured = append(c.Configured, softwareName)
+	}
+}
+
+func (s *Store) GetCheckpointPath() string {
+	return filepath.Join(s.stateDir, checkpointFilename)
+}
+
+// LoadCheckpoint returns the saved checkpoint, or nil if there is none
+func (s *Store) LoadCheckpoint() (*Checkpoint, error) {
+	data, err := os.ReadFile(s.GetCheckpointPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, err
+	}
+	if checkpoint.Answers == nil {
+		checkpoint.Answers = make(map[string]bool)
+	}
+	return &checkpoint, nil
+}
+
+// SaveCheckpoint writes the checkpoint, replacing any saved one. The file is
+// replaced atomically, so an interrupted write leaves the previous checkpoint.
+func (s *Store) SaveCheckpoint(checkpoint *Checkpoint) error {
+	checkpoint.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.stateDir, checkpointFilename+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.GetCheckpointPath())
+}
+
+// ClearCheckpoint removes the saved checkpoint, if any
+func (s *Store) ClearCheckpoint() error {
+	err := os.Remove(s.GetCheckpointPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	tempDir := t.TempDir()
+	store := &Store{stateDir: tempDir}
+
+	checkpoint := NewCheckpoint("/path/to/config")
+	checkpoint.SetCompleted("Foo")
+	checkpoint.SetCompleted("Foo")
+	checkpoint.SetConfigured("Bar")
+	checkpoint.Answers["Baz"] = true
+	checkpoint.Answers["Qux"] = false
+
+	if err := store.SaveCheckpoint(checkpoint); err != nil {
+		t.Fatalf("Failed to save checkpoint: %v", err)
+	}
+
+	loaded, err := store.LoadCheckpoint()
+	if err != nil {
+		t.Fatalf("Failed to load checkpoint: %v", err)
+	}
+	if loaded == nil {
+		t.Fatal("Saved checkpoint should be loaded")
+	}
+
+	if loaded.ConfigDir != "/path/to/config" {
+		t.Errorf("Expected config dir '/path/to/config', got '%s'", loaded.ConfigDir)
+	}
+	if len(loaded.Completed) != 1 || !loaded.IsCompleted("Foo") || loaded.IsCompleted("Bar") {
+		t.Errorf("Unexpected completed software: %v", loaded.Completed)
+	}
+	if !loaded.IsConfigured("Bar") || loaded.IsConfigured("Foo") {
+		t.Errorf("Unexpected configured software: %v", loaded.Configured)
+	}
+	if answer, ok := loaded.Answers["Baz"]; !ok || !answer {
+		t.Error("Answer for Baz should be yes")
+	}
+	if answer, ok := loaded.Answers["Qux"]; !ok || answer {
+		t.Error("Answer for Qux should be no")
+	}
+	if loaded.UpdatedAt.IsZero() {
+		t.Error("Saved checkpoint should record when it was updated")
+	}
+
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Only the checkpoint file should remain in the state dir, found %d entries", len(entries))
+	}
+}
+
+func TestLoadCheckpointMissing(t *testing.T) {
+	store := &Store{stateDir: t.TempDir()}
+
+	checkpoint, err := store.LoadCheckpoint()
+	if err != nil {
+		t.Fatalf("Missing checkpoint should not be an error: %v", err)
+	}
+	if checkpoint != nil {
+		t.Error("Missing checkpoint should load as nil")
+	}
+}
+
+func TestClearCheckpoint(t *testing.T) {
+	tempDir := t.TempDir()
+	store := &Store{stateDir: tempDir}
+
+	if err := store.ClearCheckpoint(); err != nil {
+		t.Fatalf("Clearing a missing checkpoint should not be an error: %v", err)
+	}
+
+	if err := store.SaveCheckpoint(NewCheckpoint(tempDir)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ClearCheckpoint(); err != nil {
+		t.Fatalf("Failed to clear checkpoint: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, checkpointFilename)); !os.IsNotExist(err) {
+		t.Error("Checkpoint file should be removed")
+	}
+}
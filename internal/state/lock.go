@@ -0,0 +1,104 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const lockFilename = "mac-install.lock"
+
+// Lock is held by a running mac-install, so that runs don't install software or
+// write the checklist concurrently
+type Lock struct {
+	path string
+	file *os.File
+}
+
+// LockHeldError reports that another mac-install process holds the lock
+type LockHeldError struct {
+	Path string
+	PID  int
+}
+
+func (e *LockHeldError) Error() string {
+	return fmt.Sprintf("another mac-install is running (PID %d); lock file: %s", e.PID, e.Path)
+}
+
+func (s *Store) GetLockPath() string {
+	return filepath.Join(s.stateDir, lockFilename)
+}
+
+// AcquireLock takes the run lock, an flock(2) lock on the lock file, and records
+// this process's PID in the file. The kernel drops the lock when its holder
+// exits, so a lock file left behind by a crashed run is simply locked again. If
+// a running process holds the lock, it returns a *LockHeldError.
+func (s *Store) AcquireLock() (*Lock, error) {
+	path := s.GetLockPath()
+	for {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			contents, _ := io.ReadAll(file)
+			_ = file.Close()
+			if errors.Is(err, syscall.EWOULDBLOCK) {
+				// The PID is 0 if the holder hasn't written it yet
+				pid, _ := strconv.Atoi(strings.TrimSpace(string(contents)))
+				return nil, &LockHeldError{Path: path, PID: pid}
+			}
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+
+		// A run releasing the lock removes the file, so the file locked here
+		// may have been removed since it was opened; lock the new one instead
+		if !isFileAt(file, path) {
+			_ = file.Close()
+			continue
+		}
+
+		if err := file.Truncate(0); err == nil {
+			_, err = fmt.Fprintf(file, "%d\n", os.Getpid())
+		}
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		return &Lock{path: path, file: file}, nil
+	}
+}
+
+// isFileAt reports whether file is the file currently at path
+func isFileAt(file *os.File, path string) bool {
+	openInfo, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	pathInfo, err := os.Stat(path)
+	return err == nil && os.SameFile(openInfo, pathInfo)
+}
+
+// Release gives up the lock
+func (l *Lock) Release() error {
+	if l.file == nil {
+		return nil
+	}
+	// The file is removed while still locked, so a run waiting on it notices
+	// it was replaced rather than sharing the lock with the next run
+	err := os.Remove(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file = nil
+	return err
+}
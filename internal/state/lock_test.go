@@ -0,0 +1,126 @@
+package state
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+)
+
+func TestAcquireLock(t *testing.T) {
+	store := &Store{stateDir: t.TempDir()}
+
+	lock, err := store.AcquireLock()
+	if err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+
+	contents, err := os.ReadFile(store.GetLockPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != strconv.Itoa(os.Getpid())+"\n" {
+		t.Errorf("Lock file should contain this process's PID, got %q", contents)
+	}
+
+	_, err = store.AcquireLock()
+	var heldErr *LockHeldError
+	if !errors.As(err, &heldErr) {
+		t.Fatalf("Expected LockHeldError while the lock is held, got %v", err)
+	}
+	if heldErr.PID != os.Getpid() || heldErr.Path != store.GetLockPath() {
+		t.Errorf("Unexpected lock holder: %+v", heldErr)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Failed to release lock: %v", err)
+	}
+	lock, err = store.AcquireLock()
+	if err != nil {
+		t.Fatalf("Failed to acquire released lock: %v", err)
+	}
+	_ = lock.Release()
+}
+
+func TestAcquireStaleLock(t *testing.T) {
+	// A process that has exited leaves its PID unused
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	exitedPID := cmd.Process.Pid
+
+	tests := []struct {
+		name     string
+		contents string
+	}{
+		{"exited process", strconv.Itoa(exitedPID) + "\n"},
+		// Only the flock decides whether the lock is held, not the recorded PID
+		{"running process", strconv.Itoa(os.Getpid()) + "\n"},
+		{"garbage", "not a pid, and longer than one"},
+		{"empty", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := &Store{stateDir: t.TempDir()}
+			if err := os.WriteFile(store.GetLockPath(), []byte(test.contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			lock, err := store.AcquireLock()
+			if err != nil {
+				t.Fatalf("Stale lock should be replaced: %v", err)
+			}
+			defer func() { _ = lock.Release() }()
+
+			contents, err := os.ReadFile(store.GetLockPath())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(contents) != strconv.Itoa(os.Getpid())+"\n" {
+				t.Errorf("Lock file should contain this process's PID, got %q", contents)
+			}
+		})
+	}
+}
+
+func TestAcquireLockAfterRelease(t *testing.T) {
+	store := &Store{stateDir: t.TempDir()}
+
+	lock, err := store.AcquireLock()
+	if err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+
+	// A run that opened the lock file before it was released must not end up
+	// holding a lock on the removed file alongside the next run
+	stale, err := os.Open(store.GetLockPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = stale.Close() }()
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Failed to release lock: %v", err)
+	}
+	if _, err := os.Stat(store.GetLockPath()); !os.IsNotExist(err) {
+		t.Error("Release should remove the lock file")
+	}
+	if isFileAt(stale, store.GetLockPath()) {
+		t.Error("A removed lock file should not be taken for the current one")
+	}
+
+	next, err := store.AcquireLock()
+	if err != nil {
+		t.Fatalf("Failed to acquire released lock: %v", err)
+	}
+	defer func() { _ = next.Release() }()
+	if !isFileAt(next.file, store.GetLockPath()) {
+		t.Error("The lock should be held on the file at the lock path")
+	}
+	if err := lock.Release(); err != nil {
+		t.Errorf("Releasing a lock twice should not error: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package toolchain
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ParseRuntime splits a runtime such as "python@3.12" into the tool and its
+// version, which is "latest" if not given
+func ParseRuntime(runtime string) (string, string) {
+	tool, version, _ := strings.Cut(strings.TrimSpace(runtime), "@")
+	version = strings.TrimSpace(version)
+	if version == "" {
+		version = "latest"
+	}
+	return strings.TrimSpace(tool), version
+}
+
+// IsPartialVersion reports whether a runtime version is a prefix such as "3.12"
+// or "20", which the version manager resolves to the latest matching release
+func IsPartialVersion(version string) bool {
+	return partialVersion.MatchString(version)
+}
+
+var partialVersion = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+// RuntimeArtifact returns the install directory of a runtime version managed by
+// mise or asdf. Latest and partial versions match any installed release they
+// could resolve to.
+func RuntimeArtifact(manager, runtime string) string {
+	tool, version := ParseRuntime(runtime)
+	switch {
+	case version == "latest":
+		version = "*"
+	case IsPartialVersion(version):
+		version += ".*"
+	}
+	return filepath.Join(runtimeDataDir(manager), "installs", tool, version)
+}
+
+func runtimeDataDir(manager string) string {
+	if manager == "asdf" {
+		if dir := os.Getenv("ASDF_DATA_DIR"); dir != "" {
+			return dir
+		}
+		return filepath.Join(homeDir(), ".asdf")
+	}
+	if dir := os.Getenv("MISE_DATA_DIR"); dir != "" {
+		return dir
+	}
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "mise")
+	}
+	return filepath.Join(homeDir(), ".local", "share", "mise")
+}
@@ -0,0 +1,59 @@
+package toolchain
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRuntimeArtifact(t *testing.T) {
+	for _, name := range []string{"ASDF_DATA_DIR", "MISE_DATA_DIR", "XDG_DATA_HOME"} {
+		t.Setenv(name, "")
+	}
+	home, _ := os.UserHomeDir()
+
+	tests := []struct {
+		manager  string
+		runtime  string
+		expected string
+	}{
+		{"asdf", "python@3.12.3", filepath.Join(home, ".asdf/installs/python/3.12.3")},
+		{"mise", "python@3.12", filepath.Join(home, ".local/share/mise/installs/python/3.12.*")},
+		{"mise", "node", filepath.Join(home, ".local/share/mise/installs/node/*")},
+		{"asdf", "java@temurin-21", filepath.Join(home, ".asdf/installs/java/temurin-21")},
+	}
+	for _, test := range tests {
+		if result := RuntimeArtifact(test.manager, test.runtime); result != test.expected {
+			t.Errorf("%s %s: expected '%s', got '%s'", test.manager, test.runtime, test.expected, result)
+		}
+	}
+
+	t.Setenv("MISE_DATA_DIR", "/data/mise")
+	if result := RuntimeArtifact("mise", "go@1.22.1"); result != "/data/mise/installs/go/1.22.1" {
+		t.Errorf("MISE_DATA_DIR should be honored, got '%s'", result)
+	}
+}
+
+func TestParseRuntime(t *testing.T) {
+	tests := []struct {
+		runtime string
+		tool    string
+		version string
+	}{
+		{"python@3.12", "python", "3.12"},
+		{"node", "node", "latest"},
+		{" ruby@ ", "ruby", "latest"},
+	}
+	for _, test := range tests {
+		tool, version := ParseRuntime(test.runtime)
+		if tool != test.tool || version != test.version {
+			t.Errorf("Runtime '%s': expected (%s, %s), got (%s, %s)", test.runtime, test.tool, test.version, tool, version)
+		}
+	}
+
+	for version, partial := range map[string]bool{"3": true, "3.12": true, "3.12.1": false, "latest": false, "temurin-21": false} {
+		if IsPartialVersion(version) != partial {
+			t.Errorf("IsPartialVersion(%q) should be %v", version, partial)
+		}
+	}
+}
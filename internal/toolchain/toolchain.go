@@ -0,0 +1,105 @@
+package toolchain
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// CommandArtifact returns where a language toolchain method (cargo, go_install,
+// uv_tool or pip_user) installs the command for a step's package, and whether
+// the step uses such a method. The command is assumed to be named after the
+// package, which holds for Go package paths but not always for crates and
+// Python packages.
+func CommandArtifact(step map[string]string) (string, bool) {
+	if crate, ok := step["cargo"]; ok {
+		name, _, _ := strings.Cut(strings.TrimSpace(crate), "@")
+		return filepath.Join(CargoBinDir(), name), true
+	}
+	if pkg, ok := step["go_install"]; ok {
+		return filepath.Join(GoBinDir(), GoCommandName(pkg)), true
+	}
+	if pkg, ok := step["uv_tool"]; ok {
+		return filepath.Join(UVToolBinDir(), PythonPackageName(pkg)), true
+	}
+	if pkg, ok := step["pip_user"]; ok {
+		// The user base includes the Python version, e.g. ~/Library/Python/3.9
+		return filepath.Join(homeDir(), "Library", "Python", "*", "bin", PythonPackageName(pkg)), true
+	}
+	return "", false
+}
+
+// CargoHome is where rustup installs cargo itself
+func CargoHome() string {
+	if home := os.Getenv("CARGO_HOME"); home != "" {
+		return home
+	}
+	return filepath.Join(homeDir(), ".cargo")
+}
+
+// CargoBinDir is where 'cargo install' puts commands
+func CargoBinDir() string {
+	if root := os.Getenv("CARGO_INSTALL_ROOT"); root != "" {
+		return filepath.Join(root, "bin")
+	}
+	return filepath.Join(CargoHome(), "bin")
+}
+
+// GoBinDir is where 'go install' puts commands
+func GoBinDir() string {
+	if bin := os.Getenv("GOBIN"); bin != "" {
+		return bin
+	}
+	if path := os.Getenv("GOPATH"); path != "" {
+		return filepath.Join(filepath.SplitList(path)[0], "bin")
+	}
+	return filepath.Join(homeDir(), "go", "bin")
+}
+
+// LocalBinDir is the user's bin directory, where the uv and mise installers put
+// their commands
+func LocalBinDir() string {
+	if bin := os.Getenv("XDG_BIN_HOME"); bin != "" {
+		return bin
+	}
+	return filepath.Join(homeDir(), ".local", "bin")
+}
+
+// UVToolBinDir is where 'uv tool install' puts commands
+func UVToolBinDir() string {
+	if bin := os.Getenv("UV_TOOL_BIN_DIR"); bin != "" {
+		return bin
+	}
+	return LocalBinDir()
+}
+
+// GoCommandName returns the name of the command 'go install' builds from a
+// package path, such as "gopls" for "golang.org/x/tools/gopls@latest" or
+// "mockgen" for "go.uber.org/mock/mockgen/v2"
+func GoCommandName(pkg string) string {
+	pkg, _, _ = strings.Cut(strings.TrimSpace(pkg), "@")
+	elems := strings.Split(strings.Trim(pkg, "/"), "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && majorVersionSuffix.MatchString(name) {
+		name = elems[len(elems)-2]
+	}
+	return name
+}
+
+var majorVersionSuffix = regexp.MustCompile(`^v[0-9]+$`)
+
+// PythonPackageName returns the name in a Python requirement such as
+// "black[d]>=24" or "ruff==0.4.0", which is usually also its command's name
+func PythonPackageName(requirement string) string {
+	requirement = strings.TrimSpace(requirement)
+	if idx := strings.IndexAny(requirement, "[=<>!~;@ "); idx >= 0 {
+		requirement = requirement[:idx]
+	}
+	return requirement
+}
+
+func homeDir() string {
+	home, _ := os.UserHomeDir()
+	return home
+}
@@ -0,0 +1,53 @@
+package toolchain
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCommandArtifact(t *testing.T) {
+	for _, name := range []string{"CARGO_INSTALL_ROOT", "CARGO_HOME", "GOBIN", "GOPATH", "UV_TOOL_BIN_DIR", "XDG_BIN_HOME"} {
+		t.Setenv(name, "")
+	}
+	home, _ := os.UserHomeDir()
+
+	tests := []struct {
+		step     map[string]string
+		expected string
+	}{
+		{map[string]string{"cargo": "ripgrep@14.1.0"}, filepath.Join(home, ".cargo/bin/ripgrep")},
+		{map[string]string{"go_install": "golang.org/x/tools/gopls@latest"}, filepath.Join(home, "go/bin/gopls")},
+		{map[string]string{"go_install": "go.uber.org/mock/mockgen/v2"}, filepath.Join(home, "go/bin/mockgen")},
+		{map[string]string{"uv_tool": "black[d]>=24"}, filepath.Join(home, ".local/bin/black")},
+		{map[string]string{"pip_user": "httpie==3.2.2"}, filepath.Join(home, "Library/Python/*/bin/httpie")},
+	}
+	for _, test := range tests {
+		if result, ok := CommandArtifact(test.step); !ok || result != test.expected {
+			t.Errorf("Step %v: expected '%s', got '%s'", test.step, test.expected, result)
+		}
+	}
+	if result, ok := CommandArtifact(map[string]string{"brew": "git"}); ok {
+		t.Errorf("brew should not have a command artifact, got '%s'", result)
+	}
+
+	t.Setenv("GOPATH", "/work/go")
+	if result, _ := CommandArtifact(map[string]string{"go_install": "example.com/tool"}); result != "/work/go/bin/tool" {
+		t.Errorf("GOPATH should be honored, got '%s'", result)
+	}
+	t.Setenv("CARGO_HOME", "/opt/cargo")
+	if result, _ := CommandArtifact(map[string]string{"cargo": "just"}); result != "/opt/cargo/bin/just" {
+		t.Errorf("CARGO_HOME should be honored, got '%s'", result)
+	}
+	t.Setenv("CARGO_INSTALL_ROOT", "/opt/tools")
+	if result, _ := CommandArtifact(map[string]string{"cargo": "just"}); result != "/opt/tools/bin/just" {
+		t.Errorf("CARGO_INSTALL_ROOT should be honored, got '%s'", result)
+	}
+	if CargoHome() != "/opt/cargo" {
+		t.Errorf("cargo itself should stay in CARGO_HOME, got '%s'", CargoHome())
+	}
+	t.Setenv("XDG_BIN_HOME", "/opt/bin")
+	if result, _ := CommandArtifact(map[string]string{"uv_tool": "ruff"}); result != "/opt/bin/ruff" {
+		t.Errorf("XDG_BIN_HOME should be honored, got '%s'", result)
+	}
+}
@@ -1,15 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"syscall"
+	"time"
 
 	"github.com/cdzombak/mac-install/internal/config"
 	"github.com/cdzombak/mac-install/internal/orchestrator"
+	"github.com/cdzombak/mac-install/internal/runlog"
+	"github.com/cdzombak/mac-install/internal/state"
 )
 
 var version = "<dev>"
@@ -18,10 +25,26 @@ func main() {
 	var configFile string
 	var skipOptional bool
 	var onlyTarget string
+	var keepGoing bool
+	var recoverFlag bool
+	var resume bool
+	var waitLock bool
+	var jobs int
+	var prefetch bool
+	var timeout time.Duration
+	var stepTimeout time.Duration
 	var versionFlag bool
 	flag.StringVar(&configFile, "config", "./install.yaml", "Path to configuration YAML file")
 	flag.BoolVar(&skipOptional, "skip-optional", false, "Skip all optional sections")
 	flag.StringVar(&onlyTarget, "only", "", "Install only a single piece of software matching this name")
+	flag.BoolVar(&keepGoing, "keep-going", false, "Continue past failed software, skipping only its dependents, and report failures at the end")
+	flag.BoolVar(&recoverFlag, "recover", false, "When a step fails, prompt to retry it, skip the software, open a shell, or abort (interactive terminals only)")
+	flag.BoolVar(&resume, "resume", false, "Continue from the checkpoint left by a failed or interrupted run, reusing its answers")
+	flag.BoolVar(&waitLock, "wait-lock", false, "If another mac-install is running, wait for it to finish instead of exiting")
+	flag.IntVar(&jobs, "jobs", 1, "Number of independent software items to install concurrently")
+	flag.BoolVar(&prefetch, "prefetch", true, "Download upcoming dl/archive/pkg URLs in the background")
+	flag.DurationVar(&timeout, "timeout", 0, "Abort the whole run after this long (e.g. 2h; 0 for no limit)")
+	flag.DurationVar(&stepTimeout, "step-timeout", 0, "Default time limit for each install/configure step (e.g. 20m; 0 for no limit)")
 	flag.BoolVar(&versionFlag, "version", false, "Print version and exit")
 	flag.Parse()
 
@@ -42,6 +65,18 @@ func main() {
 		log.Fatal("Cannot use -skip-optional and -only flags together")
 	}
 
+	if resume && onlyTarget != "" {
+		log.Fatal("Cannot use -resume and -only flags together")
+	}
+
+	if jobs < 1 {
+		log.Fatal("-jobs must be at least 1")
+	}
+
+	if timeout < 0 || stepTimeout < 0 {
+		log.Fatal("-timeout and -step-timeout must not be negative")
+	}
+
 	cfg, err := config.Load(configFile)
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
@@ -57,12 +92,74 @@ func main() {
 	orchestrator := orchestrator.New(cfg, absConfigDir)
 	orchestrator.SetSkipOptional(skipOptional)
 	orchestrator.SetOnlyTarget(onlyTarget)
-	if err := orchestrator.Run(); err != nil {
+	orchestrator.SetKeepGoing(keepGoing)
+	orchestrator.SetRecover(recoverFlag && stdinIsTerminal())
+	orchestrator.SetResume(resume)
+	orchestrator.SetWaitLock(waitLock)
+	orchestrator.SetJobs(jobs)
+	orchestrator.SetPrefetch(prefetch)
+	orchestrator.SetStepTimeout(stepTimeout)
+
+	runLog, err := runlog.Create()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to create log file: %v\n", err)
+	} else {
+		fmt.Printf("Logging command output to %s\n", runLog.Path())
+		orchestrator.SetRunLog(runLog)
+	}
+
+	ctx, cancel := runContext(timeout)
+	err = orchestrator.Run(ctx)
+	cancel()
+	if err != nil {
+		runLog.Printf("\nInstallation failed: %v\n", err)
+	}
+	if err := runLog.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to close log file: %v\n", err)
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Installation failed: %v\n", err)
+		if runLog != nil {
+			fmt.Fprintf(os.Stderr, "See %s for full command output\n", runLog.Path())
+		}
+		var heldErr *state.LockHeldError
+		if onlyTarget == "" && !errors.As(err, &heldErr) {
+			fmt.Fprintln(os.Stderr, "Run again with -resume to continue where this run left off")
+		}
+		if errors.Is(err, context.Canceled) {
+			os.Exit(130)
+		}
 		os.Exit(1)
 	}
 }
 
+// runContext returns a context that is cancelled on the first SIGINT or SIGTERM,
+// or once timeout elapses if it is nonzero. After the first signal, signals get
+// their default behavior again, so a second Ctrl-C exits immediately.
+func runContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if timeout <= 0 {
+		return ctx, stop
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	return timeoutCtx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// stdinIsTerminal reports whether stdin is an interactive terminal, so prompts
+// beyond those the configuration requires are only shown to someone present
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
 func printVersion() {
 	fmt.Printf("mac-install version %s\n", version)
 }